@@ -0,0 +1,42 @@
+package main
+
+import (
+	"net/url"
+	"strconv"
+)
+
+// Number of sessions shown on every page of the bookings of a user
+const bookingsPerPage = 20
+
+// Highest page of the bookings which can be requested, so that the offset of
+// the query cannot overflow
+const maxBookingsPage = 10000
+
+// pagination links a page of a list to the previous and the next page
+type pagination struct {
+	Page int    // number of the current page, starting at 1
+	Prev string // URL of the previous page, empty on the first page
+	Next string // URL of the next page, empty on the last page
+}
+
+// paginate returns the links of the page of a list shown at baseURL. The
+// query is kept in the links (e.g. the filters of the list), only its 'page'
+// parameter changes. more reports if the list continues after the page
+func paginate(baseURL string, query url.Values, page int, more bool) *pagination {
+	p := &pagination{Page: page}
+	link := func(n int) string {
+		v := url.Values{}
+		for key, values := range query {
+			v[key] = values
+		}
+		v.Set("page", strconv.Itoa(n))
+		return baseURL + "?" + v.Encode()
+	}
+	if page > 1 {
+		p.Prev = link(page - 1)
+	}
+	if more {
+		p.Next = link(page + 1)
+	}
+	return p
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestPaginate(t *testing.T) {
+	query := url.Values{"filter": {"past"}, "page": {"2"}}
+	tests := []struct {
+		name string
+		page int
+		more bool
+		prev string
+		next string
+	}{
+		{name: "OnlyPage", page: 1, more: false},
+		{name: "FirstPage", page: 1, more: true, next: "/user/bookings?filter=past&page=2"},
+		{name: "MiddlePage", page: 2, more: true, prev: "/user/bookings?filter=past&page=1", next: "/user/bookings?filter=past&page=3"},
+		{name: "LastPage", page: 3, more: false, prev: "/user/bookings?filter=past&page=2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := paginate("/user/bookings", query, tt.page, tt.more)
+			if p.Page != tt.page {
+				t.Errorf("expected page %d; got %d", tt.page, p.Page)
+			}
+			if p.Prev != tt.prev {
+				t.Errorf("expected previous page %q; got %q", tt.prev, p.Prev)
+			}
+			if p.Next != tt.next {
+				t.Errorf("expected next page %q; got %q", tt.next, p.Next)
+			}
+		})
+	}
+	// the query of the current page is not modified
+	if page := query.Get("page"); page != "2" {
+		t.Errorf("expected query page %q; got %q", "2", page)
+	}
+}
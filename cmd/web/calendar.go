@@ -0,0 +1,139 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/erodrigufer/GoTennis/pkg/forms"
+	"github.com/erodrigufer/GoTennis/pkg/models"
+)
+
+// Layout used for the 'week' query string parameter of the calendar
+const weekLayout = "2006-01-02"
+
+// The calendar displays the time slots between the first and the last hour of
+// every day. The slots are as long as the shortest slot length of the courts,
+// or calendarSlot if the courts have no booking grid
+const (
+	calendarFirstHour = 7
+	calendarLastHour  = 22
+	calendarSlot      = time.Hour
+)
+
+// A calendar holds the occupancy of one or more courts during a week
+type calendar struct {
+	BaseURL string // URL of the calendar page, without the query string
+	Week    time.Time
+	Prev    string // value of the 'week' parameter of the previous week
+	Next    string // value of the 'week' parameter of the next week
+	Courts  []*models.Court
+	Days    []*calendarDay
+}
+
+// A calendarDay holds the time slots of a single day of the week
+type calendarDay struct {
+	Date time.Time
+	Rows []*calendarRow
+}
+
+// A calendarRow holds the cells of all courts for a given time slot
+type calendarRow struct {
+	Start time.Time
+	Cells []*calendarCell
+}
+
+// A calendarCell is a time slot on a specific court, it is either booked by a
+// session or free
+type calendarCell struct {
+	Court   *models.Court
+	Start   time.Time
+	End     time.Time
+	Session *models.Session // nil if the slot is free
+	Past    bool            // the slot already started, it cannot be booked
+	Closed  string          // why the court is closed, empty if it is open
+	OffGrid bool            // sessions on the court cannot start at this time
+	loc     *time.Location  // time zone of the user who books the slot
+}
+
+// BookURL returns the URL of the create session form, prefilled with the
+// court and the time slot of the cell, which lasts the shortest duration
+// allowed on the court
+func (c *calendarCell) BookURL() string {
+	end := c.End
+	if len(c.Court.Durations) > 0 {
+		end = c.Start.Add(c.Court.Durations[0])
+	}
+	v := url.Values{}
+	v.Set("court", fmt.Sprintf("%d", c.Court.ID))
+	v.Set("start", c.Start.In(c.loc).Format(forms.DateTimeLayout))
+	v.Set("end", end.In(c.loc).Format(forms.DateTimeLayout))
+	return "/session/create?" + v.Encode()
+}
+
+// startOfWeek returns the Monday at 00:00 of the week of t, in the time zone
+// of t
+func startOfWeek(t time.Time) time.Time {
+	// time.Weekday starts counting on Sunday, shift it so that Monday is 0
+	offset := (int(t.Weekday()) + 6) % 7
+	return time.Date(t.Year(), t.Month(), t.Day()-offset, 0, 0, 0, 0, t.Location())
+}
+
+// dateOf returns the date of t in the time zone of t, at 00:00 UTC like the
+// dates of the DATE columns of the db
+func dateOf(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+// buildCalendar creates the calendar of the week starting at week for the
+// given courts, marking the slots which overlap with any of the sessions as
+// booked. Slots which started before now or in which the court is closed
+// cannot be booked. The days of the calendar are the days in the time zone of
+// week, the booking links are prefilled in the time zone loc of the user
+func buildCalendar(week time.Time, courts []*models.Court, sessions []*models.Session, availability *models.Availability, now time.Time, loc *time.Location) *calendar {
+	cal := &calendar{
+		Week:   week,
+		Prev:   week.AddDate(0, 0, -7).Format(weekLayout),
+		Next:   week.AddDate(0, 0, 7).Format(weekLayout),
+		Courts: courts,
+	}
+	step := calendarSlot
+	for _, c := range courts {
+		if c.SlotLength > 0 && c.SlotLength < step {
+			step = c.SlotLength
+		}
+	}
+	for d := 0; d < 7; d++ {
+		date := week.AddDate(0, 0, d)
+		day := &calendarDay{Date: date}
+		// the hours are counted from midnight on the clock, which differs
+		// on the days on which daylight saving time starts or ends
+		first := time.Date(date.Year(), date.Month(), date.Day(), calendarFirstHour, 0, 0, 0, date.Location())
+		last := time.Date(date.Year(), date.Month(), date.Day(), calendarLastHour, 0, 0, 0, date.Location())
+		for start := first; start.Before(last); start = start.Add(step) {
+			row := &calendarRow{Start: start}
+			for _, c := range courts {
+				cell := &calendarCell{
+					Court:   c,
+					Start:   start,
+					End:     start.Add(step),
+					Past:    start.Before(now),
+					OffGrid: !c.OnGrid(start),
+					loc:     loc,
+				}
+				cell.Closed = availability.Closed(c.ID, models.Slot{Start: cell.Start, End: cell.End})
+				for _, s := range sessions {
+					if s.CourtID == c.ID && s.Start.Before(cell.End) && s.End.After(cell.Start) {
+						cell.Session = s
+						break
+					}
+				}
+				row.Cells = append(row.Cells, cell)
+			}
+			day.Rows = append(day.Rows, row)
+		}
+		cal.Days = append(cal.Days, day)
+	}
+	return cal
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/erodrigufer/GoTennis/pkg/models"
+)
+
+func TestStartOfWeek(t *testing.T) {
+	monday := time.Date(2022, 5, 16, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name string
+		tm   time.Time
+	}{
+		{name: "Monday", tm: monday},
+		{name: "Wednesday", tm: time.Date(2022, 5, 18, 13, 45, 0, 0, time.UTC)},
+		{name: "Sunday", tm: time.Date(2022, 5, 22, 23, 59, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := startOfWeek(tt.tm)
+			if !w.Equal(monday) {
+				t.Errorf("expected %v; got %v", monday, w)
+			}
+		})
+	}
+}
+
+func TestBuildCalendar(t *testing.T) {
+	week := time.Date(2022, 5, 16, 0, 0, 0, 0, time.UTC)
+	courts := []*models.Court{{ID: 1, Name: "Court 1"}, {ID: 2, Name: "Court 2"}}
+	// A session on court 2 on Tuesday from 10:30 to 12:00, it overlaps with
+	// the slots starting at 10:00 and 11:00
+	session := &models.Session{
+		ID:      1,
+		CourtID: 2,
+		Start:   time.Date(2022, 5, 17, 10, 30, 0, 0, time.UTC),
+		End:     time.Date(2022, 5, 17, 12, 0, 0, 0, time.UTC),
+	}
+	// Court 1 is closed on Tuesday from 14:00 to 16:00
+	availability := &models.Availability{
+		Blackouts: []*models.Blackout{{
+			CourtID: 1,
+			Start:   time.Date(2022, 5, 17, 14, 0, 0, 0, time.UTC),
+			End:     time.Date(2022, 5, 17, 16, 0, 0, 0, time.UTC),
+			Reason:  "Resurfacing",
+		}},
+	}
+	// now is Tuesday at 9:00
+	now := time.Date(2022, 5, 17, 9, 0, 0, 0, time.UTC)
+	cal := buildCalendar(week, courts, []*models.Session{session}, availability, now, time.UTC)
+
+	if len(cal.Days) != 7 {
+		t.Fatalf("expected 7 days; got %d", len(cal.Days))
+	}
+	if cal.Prev != "2022-05-09" || cal.Next != "2022-05-23" {
+		t.Errorf("expected previous and next week 2022-05-09 and 2022-05-23; got %s and %s", cal.Prev, cal.Next)
+	}
+
+	// Look up the cell of a court at a given hour of Tuesday
+	cell := func(hour, court int) *calendarCell {
+		for _, row := range cal.Days[1].Rows {
+			if row.Start.Hour() == hour {
+				return row.Cells[court]
+			}
+		}
+		t.Fatalf("no row at %d:00", hour)
+		return nil
+	}
+	tests := []struct {
+		name   string
+		cell   *calendarCell
+		booked bool
+		past   bool
+		closed string
+	}{
+		{name: "Past", cell: cell(8, 1), past: true},
+		{name: "Free", cell: cell(10, 0)},
+		{name: "BookedStart", cell: cell(10, 1), booked: true},
+		{name: "BookedEnd", cell: cell(11, 1), booked: true},
+		{name: "FreeAfter", cell: cell(12, 1)},
+		{name: "Closed", cell: cell(15, 0), closed: "Resurfacing"},
+		{name: "OpenOtherCourt", cell: cell(15, 1)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if booked := tt.cell.Session != nil; booked != tt.booked {
+				t.Errorf("expected booked to be %t; got %t", tt.booked, booked)
+			}
+			if tt.cell.Past != tt.past {
+				t.Errorf("expected past to be %t; got %t", tt.past, tt.cell.Past)
+			}
+			if tt.cell.Closed != tt.closed {
+				t.Errorf("expected closed to be %q; got %q", tt.closed, tt.cell.Closed)
+			}
+		})
+	}
+
+	expected := "/session/create?court=1&end=2022-05-17T11%3A00&start=2022-05-17T10%3A00"
+	if u := cell(10, 0).BookURL(); u != expected {
+		t.Errorf("expected %q; got %q", expected, u)
+	}
+}
+
+func TestBuildCalendarGrid(t *testing.T) {
+	week := time.Date(2022, 5, 16, 0, 0, 0, 0, time.UTC)
+	// sessions on the mini court start every 30 minutes, on the full court
+	// every hour and last 90 minutes
+	courts := []*models.Court{
+		{ID: 1, Name: "Mini", SlotLength: 30 * time.Minute, Durations: []time.Duration{30 * time.Minute}},
+		{ID: 2, Name: "Full", SlotLength: time.Hour, Durations: []time.Duration{90 * time.Minute}},
+	}
+	now := time.Date(2022, 5, 9, 0, 0, 0, 0, time.UTC)
+	cal := buildCalendar(week, courts, nil, &models.Availability{}, now, time.UTC)
+
+	rows := cal.Days[0].Rows
+	if expected := 2 * (calendarLastHour - calendarFirstHour); len(rows) != expected {
+		t.Fatalf("expected %d rows; got %d", expected, len(rows))
+	}
+	// the second row starts at 7:30
+	if rows[1].Cells[0].OffGrid {
+		t.Errorf("expected the mini court to be on the grid at 7:30")
+	}
+	if !rows[1].Cells[1].OffGrid {
+		t.Errorf("expected the full court to be off the grid at 7:30")
+	}
+	expected := "/session/create?court=2&end=2022-05-16T08%3A30&start=2022-05-16T07%3A00"
+	if u := rows[0].Cells[1].BookURL(); u != expected {
+		t.Errorf("expected %q; got %q", expected, u)
+	}
+}
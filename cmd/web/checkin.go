@@ -0,0 +1,104 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"html/template"
+	"net/http"
+	"time"
+
+	"github.com/erodrigufer/GoTennis/pkg/models"
+	qrcode "github.com/skip2/go-qrcode"
+)
+
+// deriveCheckInKey derives the key signing the check-in URLs from the
+// secret of the session cookies, so that the cookie key itself is never used
+// for anything else
+func deriveCheckInKey(secret string) []byte {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte("check-in"))
+	return mac.Sum(nil)
+}
+
+// checkInToken signs the id and the start of a session with the key, so that
+// the check-in URL of a session cannot be guessed from the URL of another
+// session, and a URL stops working when the session is moved
+func checkInToken(key []byte, s *models.Session) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(fmt.Sprintf("%d:%d", s.ID, s.Start.Unix())))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// validCheckInToken reports if the token was signed with the key for the
+// session
+func validCheckInToken(key []byte, s *models.Session, token string) bool {
+	return hmac.Equal([]byte(token), []byte(checkInToken(key, s)))
+}
+
+// checkInOpen reports if players can check in to the session at the time now,
+// which is only possible within the window around the start of the session
+func checkInOpen(s *models.Session, window time.Duration, now time.Time) bool {
+	return !now.Before(s.Start.Add(-window)) && !now.After(s.Start.Add(window))
+}
+
+// canCheckIn reports if the user is allowed to see the check-in code of the
+// session: the session must not be over, and the user has to be one of its
+// players, its coach or an admin
+func canCheckIn(user *models.User, s *models.Session, participants []*models.Participant) bool {
+	if s.Cancelled() || !s.CheckedIn.IsZero() || s.End.Before(time.Now()) {
+		return false
+	}
+	return checkInPlayer(user, s, participants)
+}
+
+// checkInPlayer reports if the user may check in to the session: the user
+// who booked it, its coach, the players who did not decline their invitation
+// and the admins
+func checkInPlayer(user *models.User, s *models.Session, participants []*models.Participant) bool {
+	if user == nil {
+		return false
+	}
+	if user.IsAdmin() || user.ID == s.UserID || user.ID == s.CoachID {
+		return true
+	}
+	for _, p := range participants {
+		if p.UserID == user.ID && p.Status != models.ParticipantDeclined {
+			return true
+		}
+	}
+	return false
+}
+
+// checkInQR returns a PNG image with the QR code of the check-in URL of the
+// session, as a data URI which can be used as the source of an image
+func (app *application) checkInQR(r *http.Request, s *models.Session) (template.URL, error) {
+	// the server only listens for https connections
+	url := fmt.Sprintf("https://%s/session/%d/checkin?token=%s", r.Host, s.ID, checkInToken(app.checkInKey, s))
+	png, err := qrcode.Encode(url, qrcode.Medium, 256)
+	if err != nil {
+		return "", err
+	}
+	return template.URL("data:image/png;base64," + base64.StdEncoding.EncodeToString(png)), nil
+}
+
+// markNoShows periodically marks the sessions which ended without a check-in
+// as no-shows, it never returns. Only the sessions which end after the server
+// started are marked: the players could not check in while it was down, and
+// the sessions played before the check-in existed are left alone
+func (app *application) markNoShows(interval time.Duration) {
+	started := time.Now()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		n, err := app.session.MarkNoShows(started, time.Now())
+		if err != nil {
+			app.errorLog.Print(err)
+			continue
+		}
+		if n > 0 {
+			app.infoLog.Printf("Marked %d sessions as no-shows", n)
+		}
+	}
+}
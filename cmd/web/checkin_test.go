@@ -0,0 +1,97 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/erodrigufer/GoTennis/pkg/models"
+)
+
+func TestValidCheckInToken(t *testing.T) {
+	key := deriveCheckInKey("s6Ndh+pPbnzHbS*+9Pk8qGWhTzbpa@ge")
+	start := time.Date(2022, 5, 16, 10, 0, 0, 0, time.UTC)
+	s := &models.Session{ID: 1, Start: start}
+	token := checkInToken(key, s)
+
+	tests := []struct {
+		name     string
+		key      []byte
+		session  *models.Session
+		token    string
+		expected bool
+	}{
+		{name: "Valid", key: key, session: s, token: token, expected: true},
+		{name: "OtherSession", key: key, session: &models.Session{ID: 2, Start: start}, token: token, expected: false},
+		{name: "MovedSession", key: key, session: &models.Session{ID: 1, Start: start.Add(time.Hour)}, token: token, expected: false},
+		{name: "OtherKey", key: []byte("another secret"), session: s, token: token, expected: false},
+		{name: "SessionSecret", key: []byte("s6Ndh+pPbnzHbS*+9Pk8qGWhTzbpa@ge"), session: s, token: token, expected: false},
+		{name: "EmptyToken", key: key, session: s, token: "", expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := validCheckInToken(tt.key, tt.session, tt.token)
+			if got != tt.expected {
+				t.Errorf("expected %t; got %t", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestCheckInPlayer(t *testing.T) {
+	s := &models.Session{UserID: 1, CoachID: 2}
+	participants := []*models.Participant{
+		{UserID: 3, Status: models.ParticipantAccepted},
+		{UserID: 4, Status: models.ParticipantDeclined},
+	}
+
+	tests := []struct {
+		name     string
+		user     *models.User
+		expected bool
+	}{
+		{name: "Anonymous", user: nil, expected: false},
+		{name: "Owner", user: &models.User{ID: 1}, expected: true},
+		{name: "Coach", user: &models.User{ID: 2}, expected: true},
+		{name: "Participant", user: &models.User{ID: 3}, expected: true},
+		{name: "Declined", user: &models.User{ID: 4}, expected: false},
+		{name: "Stranger", user: &models.User{ID: 5}, expected: false},
+		{name: "Admin", user: &models.User{ID: 6, Role: models.RoleAdmin}, expected: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := checkInPlayer(tt.user, s, participants)
+			if got != tt.expected {
+				t.Errorf("expected %t; got %t", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestCheckInOpen(t *testing.T) {
+	start := time.Date(2022, 5, 16, 10, 0, 0, 0, time.UTC)
+	s := &models.Session{Start: start, End: start.Add(time.Hour)}
+	window := 15 * time.Minute
+
+	tests := []struct {
+		name     string
+		now      time.Time
+		expected bool
+	}{
+		{name: "TooEarly", now: start.Add(-16 * time.Minute), expected: false},
+		{name: "WindowOpens", now: start.Add(-window), expected: true},
+		{name: "AtTheStart", now: start, expected: true},
+		{name: "WindowCloses", now: start.Add(window), expected: true},
+		{name: "TooLate", now: start.Add(16 * time.Minute), expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := checkInOpen(s, window, tt.now)
+			if got != tt.expected {
+				t.Errorf("expected %t; got %t", tt.expected, got)
+			}
+		})
+	}
+}
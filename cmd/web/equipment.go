@@ -0,0 +1,43 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/erodrigufer/GoTennis/pkg/forms"
+	"github.com/erodrigufer/GoTennis/pkg/models"
+)
+
+// equipmentField returns the name of the form field holding the number of
+// units of an equipment item to rent
+func equipmentField(id int) string {
+	return fmt.Sprintf("equipment-%d", id)
+}
+
+// equipmentRentals validates the equipment fields of the form and returns a
+// rental for every item of which at least one unit is requested
+func equipmentRentals(form *forms.Form, items []*models.Equipment) []*models.Rental {
+	rentals := []*models.Rental{}
+	for _, e := range items {
+		field := equipmentField(e.ID)
+		form.IntRange(field, 0, e.Quantity)
+		if n := form.GetInt(field); n > 0 && n <= e.Quantity {
+			rentals = append(rentals, &models.Rental{EquipmentID: e.ID, EquipmentName: e.Name, Quantity: n})
+		}
+	}
+	return rentals
+}
+
+// rentalErrors adds a form error for every rental which asks for more units
+// than are still free, given the units already rented by id of the items
+func rentalErrors(form *forms.Form, items []*models.Equipment, rentals []*models.Rental, rented map[int]int) {
+	for _, e := range items {
+		for _, r := range rentals {
+			if r.EquipmentID != e.ID {
+				continue
+			}
+			if free := e.Quantity - rented[e.ID]; r.Quantity > free {
+				form.Errors.Add(equipmentField(e.ID), fmt.Sprintf("Only %d available at this time", free))
+			}
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/erodrigufer/GoTennis/pkg/forms"
+	"github.com/erodrigufer/GoTennis/pkg/models"
+)
+
+func TestEquipmentRentals(t *testing.T) {
+	items := []*models.Equipment{{ID: 1, Name: "Ball machine", Quantity: 1}, {ID: 2, Name: "Racket", Quantity: 6}}
+	tests := []struct {
+		name    string
+		values  url.Values
+		rentals int
+		valid   bool
+	}{
+		{name: "Nothing", values: url.Values{}, rentals: 0, valid: true},
+		{name: "Zero", values: url.Values{"equipment-2": {"0"}}, rentals: 0, valid: true},
+		{name: "Both", values: url.Values{"equipment-1": {"1"}, "equipment-2": {"2"}}, rentals: 2, valid: true},
+		{name: "TooMany", values: url.Values{"equipment-1": {"2"}}, rentals: 0, valid: false},
+		{name: "NotANumber", values: url.Values{"equipment-2": {"two"}}, rentals: 0, valid: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := forms.New(tt.values)
+			rentals := equipmentRentals(form, items)
+			if len(rentals) != tt.rentals {
+				t.Errorf("expected %d rentals; got %d", tt.rentals, len(rentals))
+			}
+			if form.Valid() != tt.valid {
+				t.Errorf("expected valid to be %t; got %v", tt.valid, form.Errors)
+			}
+		})
+	}
+}
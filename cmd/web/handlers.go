@@ -2,11 +2,16 @@ package main
 
 import (
 	"fmt"
+	"html/template"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/erodrigufer/GoTennis/pkg/forms"
 	"github.com/erodrigufer/GoTennis/pkg/models"
+	"github.com/erodrigufer/GoTennis/pkg/tennis"
 )
 
 // Handler for root URL '/'
@@ -20,15 +25,43 @@ func (app *application) root(w http.ResponseWriter, r *http.Request) {
 	//		app.notFound(w)
 	//		return
 	//	}
+	// fetch all courts, so that the sessions can be filtered by court
+	courts, err := app.courts.All()
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	// The optional query string parameter 'court' filters the sessions by
+	// court, if it is not a valid court id, respond with a 404
+	var court *models.Court
+	if q := r.URL.Query().Get("court"); q != "" {
+		id, err := strconv.Atoi(q)
+		if err != nil || id < 1 {
+			app.notFound(w)
+			return
+		}
+		court, err = app.courts.Get(id)
+		if err == models.ErrNoRecord {
+			app.notFound(w)
+			return
+		} else if err != nil {
+			app.serverError(w, err)
+			return
+		}
+	}
+	courtID := 0
+	if court != nil {
+		courtID = court.ID
+	}
 	// fetch the last sessions from database
-	s, err := app.session.Latest()
+	s, err := app.session.Latest(courtID)
 	if err != nil {
 		app.serverError(w, err)
 		return
 	}
 	// Create an instance of the templateData struct holding the slice of
 	// the latest sessions
-	dynamicData := &templateData{Sessions: s}
+	dynamicData := &templateData{Court: court, Courts: courts, Sessions: s}
 	// render page
 	app.render(w, r, "root.page.tmpl", dynamicData)
 
@@ -59,148 +92,2480 @@ func (app *application) showSession(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// structure holding dynamic data passed on to the template for page
-	// generation
-	dynamicData := &templateData{Session: s}
+	dynamicData, err := app.sessionData(r, s)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	// the form to record the result of the session
+	dynamicData.Form = app.newForm(r, url.Values{
+		"best_of":   {"3"},
+		"final_set": {tennis.FinalSetTiebreak},
+	})
 
 	// render page
 	app.render(w, r, "show.page.tmpl", dynamicData)
 }
 
-// After GET request, respond with a form to do a POST request for a
-// new tennis session
-func (app *application) createSessionForm(w http.ResponseWriter, r *http.Request) {
-	app.render(w, r, "create.page.tmpl", &templateData{
-		Form: forms.New(nil),
-	})
-}
+// sessionData collects the dynamic data displayed on the page of a session
+func (app *application) sessionData(r *http.Request, s *models.Session) (*templateData, error) {
+	var err error
+	// users can queue for the slot of an upcoming session
+	waitlist := []*models.WaitlistEntry{}
+	if !s.Cancelled() && s.Upcoming() {
+		waitlist, err = app.waitlist.ForSlot(s.CourtID, s.Start, s.End)
+		if err != nil {
+			return nil, err
+		}
+	}
 
-// Create a new tennis session after receiving a POST request: validate the data
-// from the POST request, if valid Insert() data into the database. If the data
-// is not valid, render the forms page with error messages in the invalid fields
-func (app *application) createSession(w http.ResponseWriter, r *http.Request) {
+	participants, err := app.participants.ForSession(s.ID)
+	if err != nil {
+		return nil, err
+	}
+	// the authenticated user can respond to a pending invitation
+	user := app.authenticatedUser(r)
+	var invitation *models.Participant
+	for _, p := range participants {
+		if user != nil && p.UserID == user.ID && p.Status == models.ParticipantInvited {
+			invitation = p
+		}
+	}
 
-	// First call r.ParseForm() which adds any data in POST request bodies
-	// to the r.PostForm map. This also works in the same way for PUT and PATCH
-	// requests. If there are any errors, we use our app.ClientError helper to
-	// send a 400 Bad Request response to the client
-	err := r.ParseForm()
+	rentals, err := app.equipment.ForSession(s.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	changes, err := app.session.Changes(s.ID)
 	if err != nil {
+		return nil, err
+	}
+
+	result, err := app.results.ForSession(s.ID)
+	if err == models.ErrNoRecord {
+		result = nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	// only the players get the code to check in on the court
+	var qr template.URL
+	if canCheckIn(user, s, participants) {
+		qr, err = app.checkInQR(r, s)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// structure holding dynamic data passed on to the template for page
+	// generation
+	return &templateData{
+		CanCancel:      app.canCancel(user, s),
+		CanFlag:        canFlagNoShow(user, s, participants, time.Now()),
+		CanClearNoShow: canClearNoShow(user, s),
+		CanEdit:        canEdit(user, s),
+		CanInvite:      canInvite(user, s, participants),
+		CanConfirm:     result != nil && canConfirmResult(user, s, participants, result),
+		CanRecord:      result == nil && canRecordResult(user, s, participants),
+		Changes:        changes,
+		CheckInQR:      qr,
+		Invitation:     invitation,
+		Participants:   participants,
+		Rentals:        rentals,
+		Result:         result,
+		Session:        s,
+		Waitlist:       waitlist,
+	}, nil
+}
+
+// Record the result of a played session after receiving a POST request. The
+// score is validated against the rules of tennis, if it is not possible the
+// session page is re-displayed with an error message. The result is pending
+// until another player confirms it, results recorded by an admin are
+// confirmed right away
+func (app *application) recordResult(w http.ResponseWriter, r *http.Request) {
+	id, ok := intParam(r, ":id")
+	if !ok {
+		app.notFound(w)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
 		app.clientError(w, http.StatusBadRequest)
 		return
 	}
-	// Create a new forms.Form struct containing the POSTed data from the
-	// form, then use the validation methods to check the content.
-	form := forms.New(r.PostForm) // the parameter are the url.Values POSTed
-	// into the form
-	form.Required("title", "content", "expires")
-	form.MaxLength("title", 100)
-	form.PermittedValues("expires", "365", "7", "1")
-	// If the form is not valid, redisplay the template passing in the
-	// form.Form object as the data.
+	s, err := app.session.Get(id)
+	if err == models.ErrNoRecord {
+		app.notFound(w)
+		return
+	} else if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	data, err := app.sessionData(r, s)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	user := app.authenticatedUser(r)
+	if !canRecordResult(user, s, data.Participants) {
+		app.clientError(w, http.StatusForbidden)
+		return
+	}
+
+	form := app.newForm(r, r.PostForm)
+	form.Required("score", "best_of", "final_set")
+	form.MaxLength("score", 100)
+	form.PermittedValues("best_of", "3", "5")
+	form.PermittedValues("final_set", tennis.FinalSetTiebreak, tennis.FinalSetAdvantage, tennis.FinalSetSuperTiebreak)
+	var score tennis.Score
+	format := tennis.Format{BestOf: form.GetInt("best_of"), FinalSet: form.Get("final_set")}
+	if form.Valid() {
+		score, err = tennis.ParseScore(form.Get("score"), format)
+		if err != nil {
+			form.Errors.Add("score", err.Error())
+		}
+	}
 	if !form.Valid() {
-		app.render(w, r, "create.page.tmpl", &templateData{Form: form})
+		data.Form = form
+		app.render(w, r, "show.page.tmpl", data)
 		return
 	}
-	// Because the form data (with type url.Values) has been anonymously embeded
-	// in the form.Form struct, we can use the Get() method to retrieve
-	// the validated value for a particular form field, to then add Insert the
-	// data into a new row in the SQL database
-	id, err := app.session.Insert(form.Get("title"), form.Get("content"), form.Get("expires"))
+
+	_, err = app.results.Insert(&models.MatchResult{
+		SessionID:  id,
+		Score:      score.String(),
+		BestOf:     format.BestOf,
+		FinalSet:   format.FinalSet,
+		Winner:     score.Winner(),
+		ReportedBy: user.ID,
+	})
+	// another player recorded the result in the meantime
+	if err == models.ErrResultExists {
+		app.sessionManager.Put(r, "flash", "The result of this session was already recorded")
+		http.Redirect(w, r, fmt.Sprintf("/session/%d", id), http.StatusSeeOther)
+		return
+	} else if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	// the result of an admin does not need to be confirmed
+	if user.IsAdmin() {
+		if err = app.confirmResult(s, data.Participants, user.ID); err != nil {
+			app.serverError(w, err)
+			return
+		}
+		app.sessionManager.Put(r, "flash", "The result was recorded!")
+		http.Redirect(w, r, fmt.Sprintf("/session/%d", id), http.StatusSeeOther)
+		return
+	}
+	// the other players are asked to confirm the result, the result is
+	// already stored so a failed notification is only logged
+	msg := fmt.Sprintf("%s reported the result of the session on %s, please confirm it", user.Name, humanDate(s.Start, app.location))
+	for _, player := range resultPlayers(s, data.Participants) {
+		if player == user.ID {
+			continue
+		}
+		if _, err = app.notifications.Insert(player, msg, fmt.Sprintf("/session/%d", id)); err != nil {
+			app.errorLog.Print(err)
+		}
+	}
+	app.sessionManager.Put(r, "flash", "The result was recorded, it counts once another player confirms it")
+	http.Redirect(w, r, fmt.Sprintf("/session/%d", id), http.StatusSeeOther)
+}
+
+// Confirm or reject (the 'response' field of the POSTed form) the pending
+// result of a session reported by another player. A confirmed result updates
+// the ladder and the tournament draw, a rejected result is deleted so that it
+// can be reported again
+func (app *application) respondResult(w http.ResponseWriter, r *http.Request) {
+	id, ok := intParam(r, ":id")
+	if !ok {
+		app.notFound(w)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+	response := r.PostForm.Get("response")
+	if response != models.ResultConfirmed && response != "rejected" {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+	s, err := app.session.Get(id)
+	if err == models.ErrNoRecord {
+		app.notFound(w)
+		return
+	} else if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	data, err := app.sessionData(r, s)
 	if err != nil {
 		app.serverError(w, err)
 		return
 	}
-	// Add a string value to the corresponding key ("flash") to the session data
-	// Note that if there's no existing session for the current user
-	// (or their session has expired) then a new, empty, session for them
-	// will automatically be created by the session middleware
-	app.sessionManager.Put(r, "flash", "Tennis session was successfully created!")
+	if !data.CanConfirm {
+		app.clientError(w, http.StatusForbidden)
+		return
+	}
+	user := app.authenticatedUser(r)
+	if response == models.ResultConfirmed {
+		err = app.confirmResult(s, data.Participants, user.ID)
+	} else {
+		err = app.results.Reject(id)
+	}
+	// another player responded in the meantime
+	if err == models.ErrNoRecord {
+		app.sessionManager.Put(r, "flash", "The result of this session is no longer pending")
+		http.Redirect(w, r, fmt.Sprintf("/session/%d", id), http.StatusSeeOther)
+		return
+	} else if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	msg := fmt.Sprintf("%s %s the result you reported for the session on %s", user.Name, response, humanDate(s.Start, app.location))
+	if _, err = app.notifications.Insert(data.Result.ReportedBy, msg, fmt.Sprintf("/session/%d", id)); err != nil {
+		app.errorLog.Print(err)
+	}
+	app.sessionManager.Put(r, "flash", fmt.Sprintf("You %s the result", response))
 	http.Redirect(w, r, fmt.Sprintf("/session/%d", id), http.StatusSeeOther)
 }
 
-func (app *application) signupUserForm(w http.ResponseWriter, r *http.Request) {
-	app.render(w, r, "signup.page.tmpl", &templateData{
-		Form: forms.New(nil),
+// Show all the tournaments of the club, admins can create new tournaments
+func (app *application) showTournaments(w http.ResponseWriter, r *http.Request) {
+	tournaments, err := app.tournaments.All()
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	app.render(w, r, "tournaments.page.tmpl", &templateData{
+		Tournaments: tournaments,
+		Form:        app.newForm(r, url.Values{"duration": {"90"}}),
 	})
 }
-func (app *application) signupUser(w http.ResponseWriter, r *http.Request) {
-	// parse POST request data
+
+// Create a new tournament open for registration after receiving a POST
+// request from an admin
+func (app *application) createTournament(w http.ResponseWriter, r *http.Request) {
 	err := r.ParseForm()
 	if err != nil {
 		app.clientError(w, http.StatusBadRequest)
 		return
 	}
-	// Validate the contents  of the form
-	form := forms.New(r.PostForm)
-	form.Required("name", "email", "password")
-	form.MatchesPattern("email", forms.EmailRX)
-	form.MinLength("password", 10)
-	// If there are any errors, redisplay the signup form.
+	form := app.newForm(r, r.PostForm)
+	form.Required("name", "format", "start", "duration")
+	form.MaxLength("name", 100)
+	form.PermittedValues("format", models.FormatSingleElimination, models.FormatRoundRobin)
+	form.ValidDate("start")
+	form.PermittedValues("duration", "60", "90", "120")
+	if start := form.GetDate("start"); !start.IsZero() && start.Before(dateOf(time.Now().In(app.location))) {
+		form.Errors.Add("start", "This field must not be in the past")
+	}
 	if !form.Valid() {
-		app.render(w, r, "signup.page.tmpl", &templateData{Form: form})
+		tournaments, err := app.tournaments.All()
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+		app.render(w, r, "tournaments.page.tmpl", &templateData{Tournaments: tournaments, Form: form})
 		return
 	}
-	// Try to create a new user record in the database. If the email already
-	// exists, add an error message to the form and re-display it
-	err = app.users.Insert(form.Get("name"), form.Get("email"), form.Get("password"))
-	// email address is already stored in the users table
-	if err == models.ErrDuplicateEmail {
-		form.Errors.Add("email", "Address is already in use")
-		app.render(w, r, "signup.page.tmpl", &templateData{Form: form})
+	id, err := app.tournaments.Insert(&models.Tournament{
+		Name:          form.Get("name"),
+		Format:        form.Get("format"),
+		Start:         form.GetDate("start"),
+		MatchDuration: time.Duration(form.GetInt("duration")) * time.Minute,
+	})
+	if err != nil {
+		app.serverError(w, err)
 		return
+	}
+	app.sessionManager.Put(r, "flash", "The tournament was created, members can register now!")
+	http.Redirect(w, r, fmt.Sprintf("/tournament/%d", id), http.StatusSeeOther)
+}
+
+// tournamentParam fetches the tournament of the :id URL parameter. If it
+// does not exist, an error response is sent and ok is false
+func (app *application) tournamentParam(w http.ResponseWriter, r *http.Request) (*models.Tournament, bool) {
+	id, ok := intParam(r, ":id")
+	if !ok {
+		app.notFound(w)
+		return nil, false
+	}
+	t, err := app.tournaments.Get(id)
+	if err == models.ErrNoRecord {
+		app.notFound(w)
+		return nil, false
 	} else if err != nil {
-		// another kind of error happened, on the server-side
 		app.serverError(w, err)
-		return
+		return nil, false
 	}
-	// Otherwise add a confirmation flash message to the session confirming that
-	// their signup worked and asking them to log in
-	app.sessionManager.Put(r, "flash", "Your signup was successful. Please log in.")
-	// And redirect the user to the login page.
-	http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+	return t, true
 }
-func (app *application) loginUserForm(w http.ResponseWriter, r *http.Request) {
-	app.render(w, r, "login.page.tmpl", &templateData{
-		Form: forms.New(nil),
+
+// Show a tournament, its registered players and the bracket of its draw
+func (app *application) showTournament(w http.ResponseWriter, r *http.Request) {
+	t, ok := app.tournamentParam(w, r)
+	if !ok {
+		return
+	}
+	entries, err := app.tournaments.Entries(t.ID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	matches, err := app.tournaments.Matches(t.ID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	rounds := [][]*models.TournamentMatch{}
+	for _, m := range matches {
+		if m.Round > len(rounds) {
+			rounds = append(rounds, []*models.TournamentMatch{})
+		}
+		rounds[m.Round-1] = append(rounds[m.Round-1], m)
+	}
+	app.render(w, r, "tournament.page.tmpl", &templateData{
+		Entries:    entries,
+		Rounds:     rounds,
+		Tournament: t,
 	})
 }
 
-func (app *application) loginUser(w http.ResponseWriter, r *http.Request) {
-	err := r.ParseForm()
-	if err != nil {
-		app.clientError(w, http.StatusBadRequest)
+// Register the authenticated user for a tournament which is still open for
+// registration
+func (app *application) registerTournament(w http.ResponseWriter, r *http.Request) {
+	t, ok := app.tournamentParam(w, r)
+	if !ok {
+		return
+	}
+	redirect := func(msg string) {
+		app.sessionManager.Put(r, "flash", msg)
+		http.Redirect(w, r, fmt.Sprintf("/tournament/%d", t.ID), http.StatusSeeOther)
+	}
+	if t.Status != models.TournamentRegistration {
+		redirect("The registration for this tournament is closed")
+		return
+	}
+	err := app.tournaments.Register(t.ID, app.authenticatedUser(r).ID)
+	if err == models.ErrAlreadyRegistered {
+		redirect("You are already registered for this tournament")
+		return
+	} else if err != nil {
+		app.serverError(w, err)
 		return
 	}
+	redirect("You are registered for the tournament!")
+}
 
-	// Check if the credentials are valid. If they aren't, add an error message
-	// to the form failures map and re-display the login page
-	form := forms.New(r.PostForm)
-	id, err := app.users.Authenticate(form.Get("email"), form.Get("password"))
-	if err == models.ErrInvalidCredentials {
-		form.Errors.Add("generic", "Email or Password is incorrect")
-		app.render(w, r, "login.page.tmpl", &templateData{Form: form})
+// Close the registration of a tournament and make its draw. The players are
+// seeded by their rung on the ladder, then the matches whose players are
+// known are scheduled
+func (app *application) drawTournament(w http.ResponseWriter, r *http.Request) {
+	t, ok := app.tournamentParam(w, r)
+	if !ok {
+		return
+	}
+	redirect := func(msg string) {
+		app.sessionManager.Put(r, "flash", msg)
+		http.Redirect(w, r, fmt.Sprintf("/tournament/%d", t.ID), http.StatusSeeOther)
+	}
+	entries, err := app.tournaments.Entries(t.ID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	if len(entries) < 2 {
+		redirect("At least 2 players have to register before the draw")
+		return
+	}
+	standings, err := app.ladder.Standings()
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	players := seedPlayers(entries, standings)
+	err = app.tournaments.Draw(t.ID, players, buildDraw(t.Format, players))
+	// the draw was already made by another request
+	if err == models.ErrNoRecord {
+		redirect("The draw of this tournament was already made")
 		return
 	} else if err != nil {
 		app.serverError(w, err)
 		return
 	}
+	missed, err := app.scheduleReady(t)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	if missed > 0 {
+		redirect(fmt.Sprintf("The draw was made, but no free court was found for %d matches", missed))
+		return
+	}
+	redirect("The draw was made and the matches were scheduled!")
+}
 
-	// add id of the current successfully authenticated user to the session,
-	// they are now 'logged in'
-	app.sessionManager.Put(r, "userID", id)
+// Retry to schedule the matches of a tournament for which no free court was
+// found before
+func (app *application) scheduleTournament(w http.ResponseWriter, r *http.Request) {
+	t, ok := app.tournamentParam(w, r)
+	if !ok {
+		return
+	}
+	missed, err := app.scheduleReady(t)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	msg := "All the matches are scheduled!"
+	if missed > 0 {
+		msg = fmt.Sprintf("No free court was found for %d matches", missed)
+	}
+	app.sessionManager.Put(r, "flash", msg)
+	http.Redirect(w, r, fmt.Sprintf("/tournament/%d", t.ID), http.StatusSeeOther)
+}
 
-	// Redirect the user to the create snippet page
-	http.Redirect(w, r, "/session/create", http.StatusSeeOther)
+// Show the standings of the singles ladder. The authenticated user can join
+// the ladder or challenge the players on the rungs within reach
+func (app *application) showLadder(w http.ResponseWriter, r *http.Request) {
+	standings, err := app.ladder.Standings()
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	var rating *models.Rating
+	if user := app.authenticatedUser(r); user != nil {
+		for _, s := range standings {
+			if s.UserID == user.ID {
+				rating = s
+			}
+		}
+	}
+	challengeable := map[int]bool{}
+	if rating != nil {
+		for _, s := range standings {
+			if tennis.CanChallenge(rating.Rank, s.Rank, app.ladderRules.reach) {
+				challengeable[s.UserID] = true
+			}
+		}
+	}
+	app.render(w, r, "ladder.page.tmpl", &templateData{
+		Challengeable: challengeable,
+		Rating:        rating,
+		Standings:     standings,
+	})
 }
 
-// log user out of session by removing its userID from the related user session
-func (app *application) logoutUser(w http.ResponseWriter, r *http.Request) {
-	// Remove the userID from the session data
-	app.sessionManager.Remove(r, "userID")
-	// Add a flash message indicating that the user has logged out
-	app.sessionManager.Put(r, "flash", "You have been logged out successfully!")
-	http.Redirect(w, r, "/", http.StatusSeeOther)
+// Show the standing of a player of the ladder and how the rated matches
+// changed the rating of the player
+func (app *application) showLadderPlayer(w http.ResponseWriter, r *http.Request) {
+	userID, ok := intParam(r, ":user")
+	if !ok {
+		app.notFound(w)
+		return
+	}
+	rating, err := app.ladder.Get(userID)
+	if err == models.ErrNoRecord {
+		app.notFound(w)
+		return
+	} else if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	history, err := app.ladder.History(userID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	app.render(w, r, "player.page.tmpl", &templateData{History: history, Rating: rating})
+}
+
+// Add the authenticated user to the singles ladder
+func (app *application) joinLadder(w http.ResponseWriter, r *http.Request) {
+	err := app.ladder.Join(app.authenticatedUser(r).ID)
+	if err == models.ErrAlreadyOnLadder {
+		app.sessionManager.Put(r, "flash", "You are already on the ladder")
+		http.Redirect(w, r, "/ladder", http.StatusSeeOther)
+		return
+	} else if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	app.sessionManager.Put(r, "flash", fmt.Sprintf("You joined the ladder with a rating of %d!", tennis.InitialRating))
+	http.Redirect(w, r, "/ladder", http.StatusSeeOther)
+}
+
+// Challenge a player of the ladder, players can only challenge the players
+// up to a number of rungs above them. The challenged player is notified
+func (app *application) challengePlayer(w http.ResponseWriter, r *http.Request) {
+	userID, ok := intParam(r, ":user")
+	if !ok {
+		app.notFound(w)
+		return
+	}
+	challenged, err := app.ladder.Get(userID)
+	if err == models.ErrNoRecord {
+		app.notFound(w)
+		return
+	} else if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	user := app.authenticatedUser(r)
+	challenger, err := app.ladder.Get(user.ID)
+	if err == models.ErrNoRecord {
+		app.sessionManager.Put(r, "flash", "Join the ladder to challenge other players")
+		http.Redirect(w, r, "/ladder", http.StatusSeeOther)
+		return
+	} else if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	if !tennis.CanChallenge(challenger.Rank, challenged.Rank, app.ladderRules.reach) {
+		app.sessionManager.Put(r, "flash", fmt.Sprintf("You can only challenge players up to %d rungs above you", app.ladderRules.reach))
+		http.Redirect(w, r, "/ladder", http.StatusSeeOther)
+		return
+	}
+	if _, err = app.ladder.Challenge(user.ID, challenged.UserID); err != nil {
+		app.serverError(w, err)
+		return
+	}
+	// the challenge is already stored, a failed notification is only logged
+	msg := fmt.Sprintf("%s (rung %d) challenged you to a singles match on the ladder, book a session and invite them!", user.Name, challenger.Rank)
+	if _, err = app.notifications.Insert(challenged.UserID, msg, "/session/create"); err != nil {
+		app.errorLog.Print(err)
+	}
+	app.sessionManager.Put(r, "flash", fmt.Sprintf("You challenged %s!", challenged.UserName))
+	http.Redirect(w, r, "/ladder", http.StatusSeeOther)
+}
+
+// Cancel a booked session. Sessions can only be cancelled before the
+// cancellation cutoff (e.g. 2 hours before they start), admins can cancel
+// them until they start
+func (app *application) cancelSession(w http.ResponseWriter, r *http.Request) {
+	id, ok := intParam(r, ":id")
+	if !ok {
+		app.notFound(w)
+		return
+	}
+	s, err := app.session.Get(id)
+	if err == models.ErrNoRecord {
+		app.notFound(w)
+		return
+	} else if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	user := app.authenticatedUser(r)
+	if !app.canCancel(user, s) {
+		app.sessionManager.Put(r, "flash", app.cancelRefusal(user, s))
+		http.Redirect(w, r, fmt.Sprintf("/session/%d", id), http.StatusSeeOther)
+		return
+	}
+	err = app.session.Cancel(id, user.ID)
+	// the session was cancelled by another request or started in the meantime
+	if err == models.ErrNoRecord {
+		app.notFound(w)
+		return
+	} else if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	// the other side of a lesson is told that it will not take place
+	if s.Lesson() {
+		notified := s.CoachID
+		if user.ID == s.CoachID {
+			notified = s.UserID
+		}
+		msg := fmt.Sprintf("%s cancelled the lesson on %s", user.Name, humanDate(s.Start, app.location))
+		// the session is already cancelled, a failed notification is only
+		// logged
+		if _, err = app.notifications.Insert(notified, msg, fmt.Sprintf("/session/%d", id)); err != nil {
+			app.errorLog.Print(err)
+		}
+	}
+	// the freed slot goes to the first user on the waitlist
+	app.promoteWaitlist(s)
+	app.sessionManager.Put(r, "flash", "Tennis session was cancelled!")
+	http.Redirect(w, r, fmt.Sprintf("/session/%d", id), http.StatusSeeOther)
+}
+
+// Check in to a session with the signed URL of its QR code, which is only
+// possible for the players of the session within the check-in window around
+// its start
+func (app *application) checkIn(w http.ResponseWriter, r *http.Request) {
+	id, ok := intParam(r, ":id")
+	if !ok {
+		app.notFound(w)
+		return
+	}
+	s, err := app.session.Get(id)
+	if err == models.ErrNoRecord {
+		app.notFound(w)
+		return
+	} else if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	if !validCheckInToken(app.checkInKey, s, r.URL.Query().Get("token")) {
+		app.clientError(w, http.StatusForbidden)
+		return
+	}
+	participants, err := app.participants.ForSession(s.ID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	if !checkInPlayer(app.authenticatedUser(r), s, participants) {
+		app.clientError(w, http.StatusForbidden)
+		return
+	}
+	switch {
+	case s.Cancelled():
+		app.sessionManager.Put(r, "flash", "This session was cancelled")
+	case !s.CheckedIn.IsZero():
+		app.sessionManager.Put(r, "flash", "You already checked in to this session")
+	case !checkInOpen(s, app.rules.checkIn, time.Now()):
+		loc := app.userLocation(r)
+		app.sessionManager.Put(r, "flash", fmt.Sprintf("Check-in is open from %s to %s",
+			humanDate(s.Start.Add(-app.rules.checkIn), loc), humanTime(s.Start.Add(app.rules.checkIn), loc)))
+	default:
+		err = app.session.CheckIn(id)
+		// somebody else checked in in the meantime
+		if err != nil && err != models.ErrNoRecord {
+			app.serverError(w, err)
+			return
+		}
+		app.sessionManager.Put(r, "flash", "You checked in, enjoy your game!")
+	}
+	http.Redirect(w, r, fmt.Sprintf("/session/%d", id), http.StatusSeeOther)
+}
+
+// Flag a session as a no-show of the user who booked it, the no-shows count
+// towards the suspension of the booking rights of that user
+func (app *application) flagNoShow(w http.ResponseWriter, r *http.Request) {
+	id, ok := intParam(r, ":id")
+	if !ok {
+		app.notFound(w)
+		return
+	}
+	s, err := app.session.Get(id)
+	if err == models.ErrNoRecord {
+		app.notFound(w)
+		return
+	} else if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	participants, err := app.participants.ForSession(id)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	user := app.authenticatedUser(r)
+	if !canFlagNoShow(user, s, participants, time.Now()) {
+		app.clientError(w, http.StatusForbidden)
+		return
+	}
+	err = app.session.FlagNoShow(id, user.ID)
+	// the session was flagged or cancelled by another request in the meantime
+	if err == models.ErrNoRecord {
+		app.notFound(w)
+		return
+	} else if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	// the session is already flagged, a failed notification is only logged
+	msg := fmt.Sprintf("%s flagged your session on %s as a no-show", user.Name, humanDate(s.Start, app.location))
+	if _, err = app.notifications.Insert(s.UserID, msg, fmt.Sprintf("/session/%d", id)); err != nil {
+		app.errorLog.Print(err)
+	}
+	app.sessionManager.Put(r, "flash", "The session was flagged as a no-show")
+	http.Redirect(w, r, fmt.Sprintf("/session/%d", id), http.StatusSeeOther)
+}
+
+// Clear the no-show of a session, e.g. if it was flagged by mistake, so that
+// it no longer counts towards a suspension. Only admins can do it
+func (app *application) clearNoShow(w http.ResponseWriter, r *http.Request) {
+	id, ok := intParam(r, ":id")
+	if !ok {
+		app.notFound(w)
+		return
+	}
+	s, err := app.session.Get(id)
+	if err == models.ErrNoRecord {
+		app.notFound(w)
+		return
+	} else if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	admin := app.authenticatedUser(r)
+	err = app.session.ClearNoShow(id, admin.ID)
+	// the session is not a no-show, e.g. it was cleared by another request
+	if err == models.ErrNoRecord {
+		app.sessionManager.Put(r, "flash", "This session is not a no-show")
+		http.Redirect(w, r, fmt.Sprintf("/session/%d", id), http.StatusSeeOther)
+		return
+	} else if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	// the no-show is already cleared, a failed notification is only logged
+	msg := fmt.Sprintf("The no-show of your session on %s was cleared", humanDate(s.Start, app.location))
+	if _, err = app.notifications.Insert(s.UserID, msg, fmt.Sprintf("/session/%d", id)); err != nil {
+		app.errorLog.Print(err)
+	}
+	app.sessionManager.Put(r, "flash", "The no-show was cleared")
+	http.Redirect(w, r, fmt.Sprintf("/session/%d", id), http.StatusSeeOther)
+}
+
+// Add the authenticated user to the waitlist of the slot of a session, so
+// that the user gets the slot if the session is cancelled
+func (app *application) joinWaitlist(w http.ResponseWriter, r *http.Request) {
+	id, ok := intParam(r, ":id")
+	if !ok {
+		app.notFound(w)
+		return
+	}
+	s, err := app.session.Get(id)
+	if err == models.ErrNoRecord {
+		app.notFound(w)
+		return
+	} else if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	if s.Cancelled() || !s.Upcoming() {
+		app.sessionManager.Put(r, "flash", "You can only wait for upcoming sessions")
+		http.Redirect(w, r, fmt.Sprintf("/session/%d", id), http.StatusSeeOther)
+		return
+	}
+	user := app.authenticatedUser(r)
+	_, err = app.waitlist.Insert(user.ID, s.CourtID, s.Start, s.End, s.Kind, guestRate(user), s.Title)
+	if err == models.ErrAlreadyQueued {
+		app.sessionManager.Put(r, "flash", "You are already on the waitlist")
+		http.Redirect(w, r, fmt.Sprintf("/session/%d", id), http.StatusSeeOther)
+		return
+	} else if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	app.sessionManager.Put(r, "flash", "You joined the waitlist, you will be notified if the slot becomes free!")
+	http.Redirect(w, r, fmt.Sprintf("/session/%d", id), http.StatusSeeOther)
+}
+
+// Remove the authenticated user from the waitlist of the slot of a session
+func (app *application) leaveWaitlist(w http.ResponseWriter, r *http.Request) {
+	id, ok := intParam(r, ":id")
+	if !ok {
+		app.notFound(w)
+		return
+	}
+	s, err := app.session.Get(id)
+	if err == models.ErrNoRecord {
+		app.notFound(w)
+		return
+	} else if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	err = app.waitlist.Delete(app.authenticatedUser(r).ID, s.CourtID, s.Start, s.End)
+	if err == models.ErrNoRecord {
+		app.notFound(w)
+		return
+	} else if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	app.sessionManager.Put(r, "flash", "You left the waitlist")
+	http.Redirect(w, r, fmt.Sprintf("/session/%d", id), http.StatusSeeOther)
+}
+
+// Invite another registered user, identified by the email address of the
+// POSTed form, to play in a session. Only the user who booked the session can
+// invite players, up to 1 for singles and 3 for doubles
+func (app *application) inviteParticipant(w http.ResponseWriter, r *http.Request) {
+	id, ok := intParam(r, ":id")
+	if !ok {
+		app.notFound(w)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+	s, err := app.session.Get(id)
+	if err == models.ErrNoRecord {
+		app.notFound(w)
+		return
+	} else if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	user := app.authenticatedUser(r)
+	if user.ID != s.UserID {
+		app.clientError(w, http.StatusForbidden)
+		return
+	}
+	redirect := func(msg string) {
+		app.sessionManager.Put(r, "flash", msg)
+		http.Redirect(w, r, fmt.Sprintf("/session/%d", id), http.StatusSeeOther)
+	}
+	if s.Cancelled() || !s.Upcoming() {
+		redirect("You can only invite players to upcoming sessions")
+		return
+	}
+	// Inviting a player who is not a member of the club gives the same
+	// answer as a successful invitation, so that the form cannot be used to
+	// find out which email addresses are registered. Whether the session is
+	// full is checked first for the same reason
+	participants, err := app.participants.ForSession(id)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	if !canInvite(user, s, participants) {
+		redirect(fmt.Sprintf("A %s session has at most %d players", s.Kind, s.MaxPlayers()))
+		return
+	}
+	email := r.PostForm.Get("email")
+	invited := fmt.Sprintf("An invitation was sent to %s, if it belongs to a member of the club", email)
+	invitee, err := app.users.GetByEmail(email)
+	if err == models.ErrNoRecord {
+		redirect(invited)
+		return
+	} else if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	if invitee.ID == user.ID {
+		redirect("You are already playing in this session")
+		return
+	}
+	err = app.participants.Invite(id, invitee.ID)
+	switch err {
+	case nil:
+	case models.ErrAlreadyInvited:
+		redirect(fmt.Sprintf("%s was already invited", email))
+		return
+	case models.ErrSessionFull:
+		redirect(fmt.Sprintf("A %s session has at most %d players", s.Kind, s.MaxPlayers()))
+		return
+	default:
+		app.serverError(w, err)
+		return
+	}
+	// the invitation is already stored, a failed notification is only logged
+	msg := fmt.Sprintf("%s invited you to play on %s on %s", user.Name, s.CourtName, humanDate(s.Start, app.location))
+	if _, err = app.notifications.Insert(invitee.ID, msg, fmt.Sprintf("/session/%d", id)); err != nil {
+		app.errorLog.Print(err)
+	}
+	redirect(invited)
+}
+
+// Accept or decline (the 'response' field of the POSTed form) the invitation
+// of the authenticated user to play in a session. The user who booked the
+// session is notified of the response
+func (app *application) respondInvitation(w http.ResponseWriter, r *http.Request) {
+	id, ok := intParam(r, ":id")
+	if !ok {
+		app.notFound(w)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+	response := r.PostForm.Get("response")
+	if response != models.ParticipantAccepted && response != models.ParticipantDeclined {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+	s, err := app.session.Get(id)
+	if err == models.ErrNoRecord {
+		app.notFound(w)
+		return
+	} else if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	user := app.authenticatedUser(r)
+	err = app.participants.Respond(id, user.ID, response)
+	// the user was not invited, already responded or the session is over
+	if err == models.ErrNoRecord {
+		app.sessionManager.Put(r, "flash", "You have no pending invitation to this upcoming session")
+		http.Redirect(w, r, fmt.Sprintf("/session/%d", id), http.StatusSeeOther)
+		return
+	} else if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	// the response is already stored, a failed notification is only logged
+	msg := fmt.Sprintf("%s %s your invitation to play on %s on %s", user.Name, response, s.CourtName, humanDate(s.Start, app.location))
+	if _, err = app.notifications.Insert(s.UserID, msg, fmt.Sprintf("/session/%d", id)); err != nil {
+		app.errorLog.Print(err)
+	}
+	app.sessionManager.Put(r, "flash", fmt.Sprintf("You %s the invitation", response))
+	http.Redirect(w, r, fmt.Sprintf("/session/%d", id), http.StatusSeeOther)
+}
+
+// Show the occupancy of the courts during a week as a grid of time slots. If
+// the URL contains a :court, only that court is displayed. The optional query
+// string parameter 'week' (like '2022-05-16') selects the week to display,
+// per default the current week is displayed
+func (app *application) showCalendar(w http.ResponseWriter, r *http.Request) {
+	// all courts are needed to navigate between the calendars of each court
+	all, err := app.courts.All()
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	courts := all
+	baseURL := "/calendar"
+	courtID := 0
+	if q := r.URL.Query().Get(":court"); q != "" {
+		courtID, err = strconv.Atoi(q)
+		if err != nil || courtID < 1 {
+			app.notFound(w)
+			return
+		}
+		court, err := app.courts.Get(courtID)
+		if err == models.ErrNoRecord {
+			app.notFound(w)
+			return
+		} else if err != nil {
+			app.serverError(w, err)
+			return
+		}
+		courts = []*models.Court{court}
+		baseURL = fmt.Sprintf("/calendar/%d", courtID)
+	}
+
+	// the weeks of the calendar start on Monday in the time zone of the club
+	now := time.Now()
+	week := startOfWeek(now.In(app.location))
+	if q := r.URL.Query().Get("week"); q != "" {
+		t, err := time.ParseInLocation(weekLayout, q, app.location)
+		if err != nil {
+			app.clientError(w, http.StatusBadRequest)
+			return
+		}
+		week = startOfWeek(t)
+	}
+
+	sessions, err := app.session.Between(courtID, week, week.AddDate(0, 0, 7))
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	availability, err := app.schedule.Availability(week, week.AddDate(0, 0, 7))
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	cal := buildCalendar(week, courts, sessions, availability, now, app.userLocation(r))
+	cal.BaseURL = baseURL
+	app.render(w, r, "calendar.page.tmpl", &templateData{Calendar: cal, Courts: all})
+}
+
+// After GET request, respond with a form to do a POST request for a
+// new tennis session. The form is prefilled with the query string parameters
+// (court, start and end), e.g. when coming from a free slot of the calendar
+func (app *application) createSessionForm(w http.ResponseWriter, r *http.Request) {
+	// the courts are needed to let the user choose where to play
+	courts, err := app.courts.All()
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	// the equipment can be rented together with the court
+	items, err := app.equipment.All()
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	app.render(w, r, "create.page.tmpl", &templateData{
+		Courts:    courts,
+		Equipment: items,
+		Form:      app.newForm(r, r.URL.Query()),
+	})
+}
+
+// Create a new tennis session after receiving a POST request: validate the data
+// from the POST request, if valid Insert() data into the database. If the data
+// is not valid, render the forms page with error messages in the invalid fields
+func (app *application) createSession(w http.ResponseWriter, r *http.Request) {
+
+	// First call r.ParseForm() which adds any data in POST request bodies
+	// to the r.PostForm map. This also works in the same way for PUT and PATCH
+	// requests. If there are any errors, we use our app.ClientError helper to
+	// send a 400 Bad Request response to the client
+	err := r.ParseForm()
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+	// the chosen court has to be one of the courts stored in the db
+	courts, err := app.courts.All()
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	items, err := app.equipment.All()
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	// Create a new forms.Form struct containing the POSTed data from the
+	// form, then use the validation methods to check the content.
+	form := app.newForm(r, r.PostForm) // the parameter are the url.Values POSTed
+	// into the form
+	form.Required("court", "kind", "title", "content", "start", "end")
+	form.MaxLength("title", 100)
+	form.PermittedValues("court", courtIDs(courts)...)
+	rentals := equipmentRentals(form, items)
+	form.PermittedValues("kind", models.KindSingles, models.KindDoubles)
+	// the session needs a valid time slot in the future, which ends after it
+	// starts and is not longer than the maximum duration allowed by the club
+	form.ValidDateTime("start", "end")
+	form.FutureDateTime("start")
+	form.After("end", "start")
+	form.MaxDuration("start", "end", app.rules.maxDuration)
+	// the session can optionally be repeated, either until a given date or
+	// for a given number of sessions
+	form.PermittedValues("repeat", models.FrequencyWeekly, models.FrequencyBiweekly)
+	if form.Get("repeat") != "" {
+		form.ValidDate("until")
+		form.IntRange("count", 2, models.MaxOccurrences)
+		if (form.Get("until") == "") == (form.Get("count") == "") {
+			form.Errors.Add("repeat", "Choose either an end date or a number of sessions")
+		}
+		start := form.GetTime("start")
+		if until := form.GetDate("until"); !until.IsZero() && until.Before(dateOf(start.In(app.location))) {
+			form.Errors.Add("until", "This field must not be before the start")
+		}
+	}
+	// If the form is not valid, redisplay the template passing in the
+	// form.Form object as the data.
+	if !form.Valid() {
+		app.render(w, r, "create.page.tmpl", &templateData{Courts: courts, Equipment: items, Form: form})
+		return
+	}
+	// the value of the court field was already validated against the ids of
+	// all courts, so the conversion cannot fail
+	courtID, _ := strconv.Atoi(form.Get("court"))
+	// the session has to fit the booking grid of the court, the sessions of a
+	// series share the time of the day and the duration of the first one
+	gridErrors(form, findCourt(courts, courtID), app.formSlot(form))
+	if !form.Valid() {
+		app.render(w, r, "create.page.tmpl", &templateData{Courts: courts, Equipment: items, Form: form})
+		return
+	}
+	// a repeated session is stored as a series of sessions
+	if form.Get("repeat") != "" {
+		app.createSeries(w, r, form, courts, items, courtID, rentals)
+		return
+	}
+	// the court has to be open during the whole slot
+	slot := app.formSlot(form)
+	availability, err := app.schedule.Availability(slot.Start, slot.End)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	if reason := availability.Closed(courtID, slot); reason != "" {
+		form.Errors.Add("start", fmt.Sprintf("The court is closed at this time (%s)", reason))
+		app.render(w, r, "create.page.tmpl", &templateData{Courts: courts, Equipment: items, Form: form})
+		return
+	}
+	// the member has to follow the booking rules of the membership tier
+	check, ok, err := app.tierErrors(r, form, []models.Slot{slot}, 0)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	if !ok {
+		app.render(w, r, "create.page.tmpl", &templateData{Courts: courts, Equipment: items, Form: form})
+		return
+	}
+	// the price of the session is fixed when it is booked
+	pricing, err := app.prices.Pricing()
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	user := app.authenticatedUser(r)
+	guests := guestRate(user)
+	price := pricing.Price(courtID, slot, guests)
+	// the session is only booked once the user confirmed its price
+	if !confirmedQuote(form, price) {
+		app.render(w, r, "create.page.tmpl", &templateData{Courts: courts, Equipment: items, Form: form, Quoted: true, Quote: price})
+		return
+	}
+	// Because the form data (with type url.Values) has been anonymously embeded
+	// in the form.Form struct, we can use the Get() method to retrieve
+	// the validated value for a particular form field, to then add Insert the
+	// data into a new row in the SQL database
+	s := &models.Session{
+		UserID:  user.ID,
+		CourtID: courtID,
+		Kind:    form.Get("kind"),
+		Guests:  guests,
+		Price:   price,
+		Title:   form.Get("title"),
+		Content: form.Get("content"),
+		Start:   slot.Start,
+		End:     slot.End,
+	}
+	id, err := app.session.Insert(s, rentals, check)
+	// another session was booked on the same court at an overlapping time,
+	// add an error message to the form and re-display it
+	if err == models.ErrSlotTaken {
+		form.Errors.Add("start", "This court is already booked at this time")
+		app.render(w, r, "create.page.tmpl", &templateData{Courts: courts, Equipment: items, Form: form})
+		return
+	} else if err == models.ErrCourtClosed {
+		// a blackout was added since the opening hours were checked above
+		form.Errors.Add("start", "The court is closed at this time")
+		app.render(w, r, "create.page.tmpl", &templateData{Courts: courts, Equipment: items, Form: form})
+		return
+	} else if err == models.ErrOffGrid {
+		// the grid of the court was changed since it was checked above
+		form.Errors.Add("start", "The session does not fit the booking grid of the court")
+		app.render(w, r, "create.page.tmpl", &templateData{Courts: courts, Equipment: items, Form: form})
+		return
+	} else if err == models.ErrBookingRefused {
+		// another booking of the user was made in the meantime, the check
+		// already added the reasons to the form
+		app.render(w, r, "create.page.tmpl", &templateData{Courts: courts, Equipment: items, Form: form})
+		return
+	} else if err == models.ErrEquipmentUnavailable {
+		// tell the user how many units of every item are still free
+		rented, err := app.equipment.Rented(slot)
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+		rentalErrors(form, items, rentals, rented)
+		app.render(w, r, "create.page.tmpl", &templateData{Courts: courts, Equipment: items, Form: form})
+		return
+	} else if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	// Add a string value to the corresponding key ("flash") to the session data
+	// Note that if there's no existing session for the current user
+	// (or their session has expired) then a new, empty, session for them
+	// will automatically be created by the session middleware
+	app.sessionManager.Put(r, "flash", fmt.Sprintf("Tennis session was successfully created! Price: %s", humanPrice(s.Price)))
+	http.Redirect(w, r, fmt.Sprintf("/session/%d", id), http.StatusSeeOther)
+}
+
+// Create a series of sessions from the validated create session form. The
+// series is only created if none of its sessions overlaps with another session
+// on the court and the rented equipment is free for all of them, otherwise the
+// form is re-displayed listing the conflicts
+func (app *application) createSeries(w http.ResponseWriter, r *http.Request, form *forms.Form, courts []*models.Court, items []*models.Equipment, courtID int, rentals []*models.Rental) {
+	user := app.authenticatedUser(r)
+	series := &models.Series{
+		UserID:    user.ID,
+		CourtID:   courtID,
+		Kind:      form.Get("kind"),
+		Guests:    guestRate(user),
+		Title:     form.Get("title"),
+		Frequency: form.Get("repeat"),
+		Until:     form.GetDate("until"),
+		Count:     form.GetInt("count"),
+	}
+	slots := series.Slots(app.formSlot(form))
+	// the court has to be open during every session of the series
+	availability, err := app.schedule.Availability(slots[0].Start, slots[len(slots)-1].End)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	closed := []string{}
+	for _, slot := range slots {
+		if reason := availability.Closed(courtID, slot); reason != "" {
+			closed = append(closed, fmt.Sprintf("%s (%s)", humanDate(slot.Start, form.Location), reason))
+		}
+	}
+	if len(closed) > 0 {
+		form.Errors.Add("repeat", fmt.Sprintf("The court is closed on: %s", strings.Join(closed, ", ")))
+		app.render(w, r, "create.page.tmpl", &templateData{Courts: courts, Equipment: items, Form: form})
+		return
+	}
+	check, ok, err := app.tierErrors(r, form, slots, 0)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	if !ok {
+		app.render(w, r, "create.page.tmpl", &templateData{Courts: courts, Equipment: items, Form: form})
+		return
+	}
+	pricing, err := app.prices.Pricing()
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	// every session of the series is charged its own price
+	total := 0
+	for _, slot := range slots {
+		total += pricing.Price(courtID, slot, series.Guests)
+	}
+	if !confirmedQuote(form, total) {
+		app.render(w, r, "create.page.tmpl", &templateData{Courts: courts, Equipment: items, Form: form, Quoted: true, Quote: total})
+		return
+	}
+	id, err := app.series.Insert(series, form.Get("content"), slots, pricing, rentals, check)
+	if err == models.ErrEquipmentUnavailable {
+		form.Errors.Add("equipment", "The equipment is not available for every session of the series")
+		app.render(w, r, "create.page.tmpl", &templateData{Courts: courts, Equipment: items, Form: form})
+		return
+	} else if err == models.ErrCourtClosed {
+		form.Errors.Add("repeat", "The court is closed during some sessions of the series")
+		app.render(w, r, "create.page.tmpl", &templateData{Courts: courts, Equipment: items, Form: form})
+		return
+	} else if err == models.ErrOffGrid {
+		form.Errors.Add("start", "The sessions do not fit the booking grid of the court")
+		app.render(w, r, "create.page.tmpl", &templateData{Courts: courts, Equipment: items, Form: form})
+		return
+	} else if err == models.ErrBookingRefused {
+		app.render(w, r, "create.page.tmpl", &templateData{Courts: courts, Equipment: items, Form: form})
+		return
+	} else if err == models.ErrSlotTaken {
+		// fetch the conflicting sessions to tell the user which dates are
+		// already taken
+		conflicts, err := app.series.Conflicts(courtID, slots)
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+		dates := make([]string, 0, len(conflicts))
+		for _, c := range conflicts {
+			dates = append(dates, humanDate(c.Start, form.Location))
+		}
+		form.Errors.Add("repeat", fmt.Sprintf("The court is already booked on: %s", strings.Join(dates, ", ")))
+		app.render(w, r, "create.page.tmpl", &templateData{Courts: courts, Equipment: items, Form: form})
+		return
+	} else if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	app.sessionManager.Put(r, "flash", fmt.Sprintf("%d tennis sessions were successfully created! Total price: %s", len(slots), humanPrice(total)))
+	http.Redirect(w, r, fmt.Sprintf("/series/%d", id), http.StatusSeeOther)
+}
+
+// Show a series and all of its sessions
+func (app *application) showSeries(w http.ResponseWriter, r *http.Request) {
+	id, ok := intParam(r, ":id")
+	if !ok {
+		app.notFound(w)
+		return
+	}
+	series, err := app.series.Get(id)
+	if err == models.ErrNoRecord {
+		app.notFound(w)
+		return
+	} else if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	sessions, err := app.series.Sessions(id)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	app.render(w, r, "series.page.tmpl", &templateData{Series: series, Sessions: sessions})
+}
+
+// Cancel all the upcoming sessions of a series, only the user who created the
+// series is allowed to cancel it
+func (app *application) cancelSeries(w http.ResponseWriter, r *http.Request) {
+	series, ok := app.ownSeries(w, r)
+	if !ok {
+		return
+	}
+	// the sessions starting before the cancellation cutoff are kept, unless
+	// an admin cancels the series
+	user := app.authenticatedUser(r)
+	notBefore := time.Now()
+	if !user.IsAdmin() {
+		notBefore = notBefore.Add(app.rules.cancelCutoff)
+	}
+	// the sessions are fetched beforehand, to give their slots to the users
+	// on the waitlists afterwards
+	sessions, err := app.series.Sessions(series.ID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	n, err := app.series.Cancel(series.ID, user.ID, notBefore)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	for _, s := range sessions {
+		if !s.Cancelled() && s.Start.After(notBefore) {
+			app.promoteWaitlist(s)
+		}
+	}
+	app.sessionManager.Put(r, "flash", fmt.Sprintf("%d tennis sessions were cancelled!", n))
+	http.Redirect(w, r, fmt.Sprintf("/series/%d", series.ID), http.StatusSeeOther)
+}
+
+// Cancel a single upcoming session of a series, only the user who created the
+// series is allowed to cancel it
+func (app *application) cancelSeriesSession(w http.ResponseWriter, r *http.Request) {
+	series, ok := app.ownSeries(w, r)
+	if !ok {
+		return
+	}
+	sessionID, ok := intParam(r, ":session")
+	if !ok {
+		app.notFound(w)
+		return
+	}
+	s, err := app.session.Get(sessionID)
+	if err == models.ErrNoRecord || (err == nil && s.SeriesID != series.ID) {
+		app.notFound(w)
+		return
+	} else if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	user := app.authenticatedUser(r)
+	if !app.canCancel(user, s) {
+		app.sessionManager.Put(r, "flash", app.cancelRefusal(user, s))
+		http.Redirect(w, r, fmt.Sprintf("/series/%d", series.ID), http.StatusSeeOther)
+		return
+	}
+	err = app.session.Cancel(s.ID, user.ID)
+	if err == models.ErrNoRecord {
+		app.notFound(w)
+		return
+	} else if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	app.promoteWaitlist(s)
+	app.sessionManager.Put(r, "flash", "Tennis session was cancelled!")
+	http.Redirect(w, r, fmt.Sprintf("/series/%d", series.ID), http.StatusSeeOther)
+}
+
+// ownSeries fetches the series of the :id URL parameter and checks that it
+// was created by the authenticated user (or that the user is an admin). If
+// not, an error response is sent and ok is false
+func (app *application) ownSeries(w http.ResponseWriter, r *http.Request) (*models.Series, bool) {
+	id, ok := intParam(r, ":id")
+	if !ok {
+		app.notFound(w)
+		return nil, false
+	}
+	series, err := app.series.Get(id)
+	if err == models.ErrNoRecord {
+		app.notFound(w)
+		return nil, false
+	} else if err != nil {
+		app.serverError(w, err)
+		return nil, false
+	}
+	user := app.authenticatedUser(r)
+	if series.UserID != user.ID && !user.IsAdmin() {
+		app.clientError(w, http.StatusForbidden)
+		return nil, false
+	}
+	return series, true
+}
+
+func (app *application) signupUserForm(w http.ResponseWriter, r *http.Request) {
+	app.render(w, r, "signup.page.tmpl", &templateData{
+		Form: app.newForm(r, nil),
+	})
+}
+func (app *application) signupUser(w http.ResponseWriter, r *http.Request) {
+	// parse POST request data
+	err := r.ParseForm()
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+	// Validate the contents  of the form
+	form := app.newForm(r, r.PostForm)
+	form.Required("name", "email", "password")
+	form.MatchesPattern("email", forms.EmailRX)
+	form.MinLength("password", 10)
+	// If there are any errors, redisplay the signup form.
+	if !form.Valid() {
+		app.render(w, r, "signup.page.tmpl", &templateData{Form: form})
+		return
+	}
+	// Try to create a new user record in the database. If the email already
+	// exists, add an error message to the form and re-display it
+	err = app.users.Insert(form.Get("name"), form.Get("email"), form.Get("password"))
+	// email address is already stored in the users table
+	if err == models.ErrDuplicateEmail {
+		form.Errors.Add("email", "Address is already in use")
+		app.render(w, r, "signup.page.tmpl", &templateData{Form: form})
+		return
+	} else if err != nil {
+		// another kind of error happened, on the server-side
+		app.serverError(w, err)
+		return
+	}
+	// Otherwise add a confirmation flash message to the session confirming that
+	// their signup worked and asking them to log in
+	app.sessionManager.Put(r, "flash", "Your signup was successful. Please log in.")
+	// And redirect the user to the login page.
+	http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+}
+func (app *application) loginUserForm(w http.ResponseWriter, r *http.Request) {
+	app.render(w, r, "login.page.tmpl", &templateData{
+		Form: app.newForm(r, nil),
+	})
+}
+
+func (app *application) loginUser(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	// Check if the credentials are valid. If they aren't, add an error message
+	// to the form failures map and re-display the login page
+	form := app.newForm(r, r.PostForm)
+	id, err := app.users.Authenticate(form.Get("email"), form.Get("password"))
+	if err == models.ErrInvalidCredentials {
+		form.Errors.Add("generic", "Email or Password is incorrect")
+		app.render(w, r, "login.page.tmpl", &templateData{Form: form})
+		return
+	} else if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	// add id of the current successfully authenticated user to the session,
+	// they are now 'logged in'
+	app.sessionManager.Put(r, "userID", id)
+
+	// Redirect the user to the create snippet page
+	http.Redirect(w, r, "/session/create", http.StatusSeeOther)
+}
+
+// log user out of session by removing its userID from the related user session
+func (app *application) logoutUser(w http.ResponseWriter, r *http.Request) {
+	// Remove the userID from the session data
+	app.sessionManager.Remove(r, "userID")
+	// Add a flash message indicating that the user has logged out
+	app.sessionManager.Put(r, "flash", "You have been logged out successfully!")
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+// Show the form to edit the profile of the authenticated user, prefilled with
+// the current NTRP rating and preferred times to play
+func (app *application) profileForm(w http.ResponseWriter, r *http.Request) {
+	user := app.authenticatedUser(r)
+	data := url.Values{"times": user.PlayTimes, "timezone": {user.TimeZone}}
+	if user.Rated() {
+		data.Set("skill", fmt.Sprintf("%.1f", user.Skill))
+	}
+	app.renderProfile(w, r, app.newForm(r, data))
+}
+
+// renderProfile renders the profile of the authenticated user with the form
+// to update it
+func (app *application) renderProfile(w http.ResponseWriter, r *http.Request, form *forms.Form) {
+	// users can see until when their booking rights are suspended
+	suspensions, err := app.suspensions(app.authenticatedUser(r).ID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	app.render(w, r, "profile.page.tmpl", &templateData{Form: form, Suspensions: suspensions, TimeZone: app.location.String()})
+}
+
+// Update the NTRP rating, the preferred times to play and the time zone of the
+// authenticated user
+func (app *application) updateProfile(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+	// an empty skill means that the user is not rated
+	form := app.newForm(r, r.PostForm)
+	form.PermittedValues("skill", skillLevels()...)
+	form.PermittedMultiValues("times", models.PlayTimes...)
+	form.ValidTimeZone("timezone")
+	if !form.Valid() {
+		app.renderProfile(w, r, form)
+		return
+	}
+	// the value of the skill field was already validated against the NTRP
+	// ratings, so the conversion can only fail for an unrated user, whose
+	// skill is 0
+	skill, _ := strconv.ParseFloat(form.Get("skill"), 64)
+	err = app.users.UpdateProfile(app.authenticatedUser(r).ID, skill, form.Values["times"], form.Get("timezone"))
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	app.sessionManager.Put(r, "flash", "Your profile was updated!")
+	http.Redirect(w, r, "/partners", http.StatusSeeOther)
+}
+
+// Show the members which are compatible with the authenticated user (similar
+// level and a shared preferred time to play) and the open sessions of
+// members of a similar level which the user can join
+func (app *application) findPartners(w http.ResponseWriter, r *http.Request) {
+	user := app.authenticatedUser(r)
+	if !user.Rated() {
+		app.sessionManager.Put(r, "flash", "Set your NTRP rating to find a partner")
+		http.Redirect(w, r, "/user/profile", http.StatusSeeOther)
+		return
+	}
+	partners, err := app.users.Partners(user)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	sessions, err := app.session.OpenFor(user)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	app.render(w, r, "partners.page.tmpl", &templateData{Sessions: sessions, Users: partners})
+}
+
+// Open a session to other members of a similar level or close it again (the
+// 'open' field of the POSTed form), only the user who booked the session can
+// do it
+func (app *application) openSession(w http.ResponseWriter, r *http.Request) {
+	id, ok := intParam(r, ":id")
+	if !ok {
+		app.notFound(w)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+	s, err := app.session.Get(id)
+	if err == models.ErrNoRecord {
+		app.notFound(w)
+		return
+	} else if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	if app.authenticatedUser(r).ID != s.UserID {
+		app.clientError(w, http.StatusForbidden)
+		return
+	}
+	if s.Cancelled() || !s.Upcoming() {
+		app.sessionManager.Put(r, "flash", "Only upcoming sessions can be opened to other members")
+		http.Redirect(w, r, fmt.Sprintf("/session/%d", id), http.StatusSeeOther)
+		return
+	}
+	open := r.PostForm.Get("open") == "true"
+	err = app.session.SetOpen(id, open)
+	// the session was cancelled by another request in the meantime
+	if err == models.ErrNoRecord {
+		app.notFound(w)
+		return
+	} else if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	msg := "Other members can now join the session!"
+	if !open {
+		msg = "The session is no longer open to other members"
+	}
+	app.sessionManager.Put(r, "flash", msg)
+	http.Redirect(w, r, fmt.Sprintf("/session/%d", id), http.StatusSeeOther)
+}
+
+// Join an open session as a player, the authenticated user has to be of a
+// similar level as the user who booked it. The user who booked the session
+// is notified
+func (app *application) joinSession(w http.ResponseWriter, r *http.Request) {
+	id, ok := intParam(r, ":id")
+	if !ok {
+		app.notFound(w)
+		return
+	}
+	s, err := app.session.Get(id)
+	if err == models.ErrNoRecord {
+		app.notFound(w)
+		return
+	} else if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	redirect := func(msg string) {
+		app.sessionManager.Put(r, "flash", msg)
+		http.Redirect(w, r, fmt.Sprintf("/session/%d", id), http.StatusSeeOther)
+	}
+	user := app.authenticatedUser(r)
+	if !s.Open || s.Cancelled() || !s.Upcoming() || user.ID == s.UserID {
+		redirect("You cannot join this session")
+		return
+	}
+	owner, err := app.users.Get(s.UserID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	if !user.SimilarLevel(owner) {
+		redirect("This session is open to members of a similar level only")
+		return
+	}
+	err = app.participants.Join(id, user.ID)
+	switch err {
+	case nil:
+	case models.ErrAlreadyInvited:
+		redirect("You are already taking part in this session")
+		return
+	case models.ErrSessionFull:
+		redirect("This session is already full")
+		return
+	default:
+		app.serverError(w, err)
+		return
+	}
+	// the user already joined, a failed notification is only logged
+	msg := fmt.Sprintf("%s joined your session on %s on %s", user.Name, s.CourtName, humanDate(s.Start, app.location))
+	if _, err = app.notifications.Insert(s.UserID, msg, fmt.Sprintf("/session/%d", id)); err != nil {
+		app.errorLog.Print(err)
+	}
+	redirect("You joined the session!")
+}
+
+// Display the form to book a lesson with a coach, listing the upcoming
+// availability of all coaches
+func (app *application) lessonForm(w http.ResponseWriter, r *http.Request) {
+	td, err := app.lessonData(app.newForm(r, r.URL.Query()))
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	app.render(w, r, "lesson.page.tmpl", td)
+}
+
+// lessonData returns the data of the lesson form: the courts, the coaches and
+// their upcoming availability
+func (app *application) lessonData(form *forms.Form) (*templateData, error) {
+	courts, err := app.courts.All()
+	if err != nil {
+		return nil, err
+	}
+	coaches, err := app.coaches.All()
+	if err != nil {
+		return nil, err
+	}
+	windows, err := app.coaches.Windows(0, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	return &templateData{Courts: courts, Users: coaches, Windows: windows, Form: form}, nil
+}
+
+// Book a lesson with a coach after receiving a POST request, both the coach
+// and the court are reserved for the lesson. The coach is notified
+func (app *application) bookLesson(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+	form := app.newForm(r, r.PostForm)
+	td, err := app.lessonData(form)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	coachIDs := make([]string, 0, len(td.Users))
+	for _, c := range td.Users {
+		coachIDs = append(coachIDs, strconv.Itoa(c.ID))
+	}
+	form.Required("coach", "court", "start", "end")
+	form.PermittedValues("coach", coachIDs...)
+	form.PermittedValues("court", courtIDs(td.Courts)...)
+	form.ValidDateTime("start", "end")
+	form.FutureDateTime("start")
+	form.After("end", "start")
+	form.MaxDuration("start", "end", app.rules.maxDuration)
+	if !form.Valid() {
+		app.render(w, r, "lesson.page.tmpl", td)
+		return
+	}
+	coachID, _ := strconv.Atoi(form.Get("coach"))
+	courtID, _ := strconv.Atoi(form.Get("court"))
+	gridErrors(form, findCourt(td.Courts, courtID), app.formSlot(form))
+	if !form.Valid() {
+		app.render(w, r, "lesson.page.tmpl", td)
+		return
+	}
+	var coach *models.User
+	for _, c := range td.Users {
+		if c.ID == coachID {
+			coach = c
+		}
+	}
+	// the court has to be open during the whole lesson
+	slot := app.formSlot(form)
+	availability, err := app.schedule.Availability(slot.Start, slot.End)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	if reason := availability.Closed(courtID, slot); reason != "" {
+		form.Errors.Add("start", fmt.Sprintf("The court is closed at this time (%s)", reason))
+		app.render(w, r, "lesson.page.tmpl", td)
+		return
+	}
+	// a lesson is subject to the rules of the tier like any other session
+	check, ok, err := app.tierErrors(r, form, []models.Slot{slot}, 0)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	if !ok {
+		app.render(w, r, "lesson.page.tmpl", td)
+		return
+	}
+	// the court of a lesson is charged the rate of the user who books it
+	pricing, err := app.prices.Pricing()
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	user := app.authenticatedUser(r)
+	price := pricing.Price(courtID, slot, guestRate(user))
+	if !confirmedQuote(form, price) {
+		td.Quoted, td.Quote = true, price
+		app.render(w, r, "lesson.page.tmpl", td)
+		return
+	}
+	lesson := &models.Session{
+		UserID:  user.ID,
+		CourtID: courtID,
+		CoachID: coachID,
+		Kind:    models.KindSingles,
+		Guests:  guestRate(user),
+		Price:   price,
+		Title:   fmt.Sprintf("Lesson with %s", coach.Name),
+		Content: form.Get("content"),
+		Start:   slot.Start,
+		End:     slot.End,
+	}
+	id, err := app.coaches.BookLesson(lesson, check)
+	switch err {
+	case nil:
+	case models.ErrCoachUnavailable:
+		form.Errors.Add("coach", "The coach is not available at this time")
+		app.render(w, r, "lesson.page.tmpl", td)
+		return
+	case models.ErrSlotTaken:
+		form.Errors.Add("start", "This court is already booked at this time")
+		app.render(w, r, "lesson.page.tmpl", td)
+		return
+	case models.ErrCourtClosed:
+		form.Errors.Add("start", "The court is closed at this time")
+		app.render(w, r, "lesson.page.tmpl", td)
+		return
+	case models.ErrOffGrid:
+		form.Errors.Add("start", "The lesson does not fit the booking grid of the court")
+		app.render(w, r, "lesson.page.tmpl", td)
+		return
+	case models.ErrBookingRefused:
+		app.render(w, r, "lesson.page.tmpl", td)
+		return
+	default:
+		app.serverError(w, err)
+		return
+	}
+	// the lesson is already booked, a failed notification is only logged
+	msg := fmt.Sprintf("%s booked a lesson with you on %s", user.Name, humanDate(slot.Start, app.location))
+	if _, err = app.notifications.Insert(coachID, msg, fmt.Sprintf("/session/%d", id)); err != nil {
+		app.errorLog.Print(err)
+	}
+	app.sessionManager.Put(r, "flash", fmt.Sprintf("Your lesson was successfully booked! Court price: %s", humanPrice(lesson.Price)))
+	http.Redirect(w, r, fmt.Sprintf("/session/%d", id), http.StatusSeeOther)
+}
+
+// Show the dashboard of the authenticated coach: the upcoming lessons and
+// the windows in which the coach is available
+func (app *application) coachDashboard(w http.ResponseWriter, r *http.Request) {
+	app.renderCoachDashboard(w, r, app.newForm(r, nil))
+}
+
+// renderCoachDashboard renders the dashboard of the authenticated coach with
+// the form to add a window
+func (app *application) renderCoachDashboard(w http.ResponseWriter, r *http.Request, form *forms.Form) {
+	coach := app.authenticatedUser(r)
+	lessons, err := app.coaches.Lessons(coach.ID, time.Now())
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	windows, err := app.coaches.Windows(coach.ID, time.Now())
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	app.render(w, r, "coach.page.tmpl", &templateData{Sessions: lessons, Windows: windows, Form: form})
+}
+
+// Publish a new window in which the authenticated coach is available to give
+// lessons
+func (app *application) addCoachWindow(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+	form := app.newForm(r, r.PostForm)
+	form.Required("start", "end")
+	form.ValidDateTime("start", "end")
+	form.FutureDateTime("start")
+	form.After("end", "start")
+	if !form.Valid() {
+		app.renderCoachDashboard(w, r, form)
+		return
+	}
+	_, err = app.coaches.InsertWindow(app.authenticatedUser(r).ID, form.GetTime("start"), form.GetTime("end"))
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	app.sessionManager.Put(r, "flash", "Your availability was published!")
+	http.Redirect(w, r, "/coach", http.StatusSeeOther)
+}
+
+// Remove a window of the authenticated coach, the lessons already booked in
+// it still take place
+func (app *application) deleteCoachWindow(w http.ResponseWriter, r *http.Request) {
+	id, ok := intParam(r, ":id")
+	if !ok {
+		app.notFound(w)
+		return
+	}
+	err := app.coaches.DeleteWindow(id, app.authenticatedUser(r).ID)
+	if err == models.ErrNoRecord {
+		app.notFound(w)
+		return
+	} else if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	app.sessionManager.Put(r, "flash", "The availability was removed")
+	http.Redirect(w, r, "/coach", http.StatusSeeOther)
+}
+
+// Show the membership tiers of the club and their booking rules
+func (app *application) showTiers(w http.ResponseWriter, r *http.Request) {
+	tiers, err := app.tiers.All()
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	app.render(w, r, "tiers.page.tmpl", &templateData{Tiers: tiers})
+}
+
+// tierParam returns the tier named in the URL, if it does not exist a 404 is
+// sent and false is returned
+func (app *application) tierParam(w http.ResponseWriter, r *http.Request) (*models.Tier, bool) {
+	tier, err := app.tiers.Get(r.URL.Query().Get(":name"))
+	if err == models.ErrNoRecord {
+		app.notFound(w)
+		return nil, false
+	} else if err != nil {
+		app.serverError(w, err)
+		return nil, false
+	}
+	return tier, true
+}
+
+// Display the form to edit the booking rules of a tier
+func (app *application) tierForm(w http.ResponseWriter, r *http.Request) {
+	tier, ok := app.tierParam(w, r)
+	if !ok {
+		return
+	}
+	data := url.Values{
+		"horizon": {strconv.Itoa(tier.Horizon)},
+		"active":  {strconv.Itoa(tier.Quota.MaxActive)},
+		"weekly":  {strconv.Itoa(int(tier.Quota.MaxWeekly / time.Minute))},
+		"prime":   {strconv.Itoa(tier.Quota.MaxWeeklyPrime)},
+		"opens":   {""},
+		"closes":  {""},
+	}
+	if tier.Closes != 0 {
+		data.Set("opens", humanTimeOfDay(tier.Opens))
+		data.Set("closes", humanTimeOfDay(tier.Closes))
+	}
+	app.render(w, r, "tier.page.tmpl", &templateData{Tier: tier, Form: app.newForm(r, data)})
+}
+
+// Store the booking rules of a tier, they apply to the next bookings of its
+// members
+func (app *application) updateTier(w http.ResponseWriter, r *http.Request) {
+	tier, ok := app.tierParam(w, r)
+	if !ok {
+		return
+	}
+	err := r.ParseForm()
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+	form := app.newForm(r, r.PostForm)
+	form.Required("horizon", "active", "weekly", "prime")
+	form.IntRange("horizon", 0, 365)
+	form.IntRange("active", 0, 100)
+	form.IntRange("weekly", 0, 7*24*60)
+	form.IntRange("prime", 0, 100)
+	// the allowed hours are optional, but they need both a start and an end
+	form.ValidTimeOfDay("opens", "closes")
+	if (form.Get("opens") == "") != (form.Get("closes") == "") {
+		form.Errors.Add("closes", "Set both the start and the end of the allowed hours, or none of them")
+	} else if form.Get("closes") != "" && form.GetTimeOfDay("closes") <= form.GetTimeOfDay("opens") {
+		form.Errors.Add("closes", "This field must be after the start")
+	}
+	if !form.Valid() {
+		app.render(w, r, "tier.page.tmpl", &templateData{Tier: tier, Form: form})
+		return
+	}
+	tier.Horizon = form.GetInt("horizon")
+	tier.Opens = form.GetTimeOfDay("opens")
+	tier.Closes = form.GetTimeOfDay("closes")
+	tier.Quota = models.Quota{
+		MaxActive:      form.GetInt("active"),
+		MaxWeekly:      time.Duration(form.GetInt("weekly")) * time.Minute,
+		MaxWeeklyPrime: form.GetInt("prime"),
+	}
+	err = app.tiers.Update(tier)
+	if err == models.ErrNoRecord {
+		app.notFound(w)
+		return
+	} else if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	app.sessionManager.Put(r, "flash", fmt.Sprintf("The %s tier was updated!", tier.Name))
+	http.Redirect(w, r, "/admin/tiers", http.StatusSeeOther)
+}
+
+// Show all the users with their role and membership tier, so that admins can
+// change their role or move them to another tier
+func (app *application) showMembers(w http.ResponseWriter, r *http.Request) {
+	users, err := app.users.All()
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	tiers, err := app.tiers.All()
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	suspensions, err := app.suspensions(0)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	app.render(w, r, "members.page.tmpl", &templateData{Users: users, Tiers: tiers, Suspensions: suspensions})
+}
+
+// Move a user to the membership tier of the POSTed form
+func (app *application) setMemberTier(w http.ResponseWriter, r *http.Request) {
+	id, ok := intParam(r, ":id")
+	if !ok {
+		app.notFound(w)
+		return
+	}
+	err := r.ParseForm()
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+	user, err := app.users.Get(id)
+	if err == models.ErrNoRecord {
+		app.notFound(w)
+		return
+	} else if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	tier, err := app.tiers.Get(r.PostForm.Get("tier"))
+	if err == models.ErrNoRecord {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	} else if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	err = app.users.SetTier(user.ID, tier.Name)
+	if err == models.ErrNoRecord {
+		app.notFound(w)
+		return
+	} else if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	app.sessionManager.Put(r, "flash", fmt.Sprintf("%s is now a %s member", user.Name, tier.Name))
+	http.Redirect(w, r, "/admin/members", http.StatusSeeOther)
+}
+
+// Change the role of a user to the role of the POSTed form, e.g. to let a
+// member give lessons as a coach. Admins cannot change their own role, so
+// that the club is never left without an admin by mistake
+func (app *application) setMemberRole(w http.ResponseWriter, r *http.Request) {
+	id, ok := intParam(r, ":id")
+	if !ok {
+		app.notFound(w)
+		return
+	}
+	err := r.ParseForm()
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+	role := r.PostForm.Get("role")
+	if role != models.RoleMember && role != models.RoleCoach && role != models.RoleAdmin {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+	if id == app.authenticatedUser(r).ID {
+		app.sessionManager.Put(r, "flash", "You cannot change your own role")
+		http.Redirect(w, r, "/admin/members", http.StatusSeeOther)
+		return
+	}
+	user, err := app.users.Get(id)
+	if err == models.ErrNoRecord {
+		app.notFound(w)
+		return
+	} else if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	err = app.users.SetRole(user.ID, role)
+	if err == models.ErrNoRecord {
+		app.notFound(w)
+		return
+	} else if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	app.sessionManager.Put(r, "flash", fmt.Sprintf("%s is now a %s", user.Name, role))
+	http.Redirect(w, r, "/admin/members", http.StatusSeeOther)
+}
+
+// Show the form to close courts because of the weather, the outdoor courts
+// are selected by default
+func (app *application) rainOutForm(w http.ResponseWriter, r *http.Request) {
+	courts, err := app.courts.All()
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	outdoor := []string{}
+	for _, c := range courts {
+		if c.Outdoor {
+			outdoor = append(outdoor, strconv.Itoa(c.ID))
+		}
+	}
+	app.render(w, r, "rainout.page.tmpl", &templateData{
+		Courts: courts,
+		Form:   app.newForm(r, url.Values{"courts": outdoor}),
+	})
+}
+
+// Close the courts of the POSTed form for a time range and cancel all the
+// sessions booked on them in that range. The players of every cancelled
+// session are notified, and the cancelled tournament matches are scheduled
+// again
+func (app *application) rainOut(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+	courts, err := app.courts.All()
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	form := app.newForm(r, r.PostForm)
+	form.Required("courts", "start", "end", "reason")
+	form.PermittedMultiValues("courts", courtIDs(courts)...)
+	form.MaxLength("reason", 255)
+	form.ValidDateTime("start", "end")
+	form.FutureDateTime("end")
+	form.After("end", "start")
+	if !form.Valid() {
+		app.render(w, r, "rainout.page.tmpl", &templateData{Courts: courts, Form: form})
+		return
+	}
+	ids := make([]int, 0, len(form.Values["courts"]))
+	for _, v := range form.Values["courts"] {
+		id, _ := strconv.Atoi(v)
+		ids = append(ids, id)
+	}
+	reason := form.Get("reason")
+	admin := app.authenticatedUser(r)
+	cancelled, tournaments, err := app.schedule.RainOut(ids, form.GetTime("start"), form.GetTime("end"), reason, admin.ID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	// the sessions are already cancelled, a failed notification is only
+	// logged so that the players of the other sessions are still notified
+	for _, s := range cancelled {
+		participants, err := app.participants.ForSession(s.ID)
+		if err != nil {
+			app.errorLog.Print(err)
+		}
+		msg := fmt.Sprintf("Your session on %s at %s was cancelled: %s", s.CourtName, humanDate(s.Start, app.location), reason)
+		for _, userID := range sessionRecipients(s, participants) {
+			if _, err = app.notifications.Insert(userID, msg, fmt.Sprintf("/session/%d", s.ID)); err != nil {
+				app.errorLog.Print(err)
+			}
+		}
+	}
+	// the tournament matches of the cancelled sessions are booked again,
+	// after the closed period. Errors are only logged, an admin can retry to
+	// schedule the matches of a tournament
+	scheduled := map[int]bool{}
+	for _, id := range tournaments {
+		if scheduled[id] {
+			continue
+		}
+		scheduled[id] = true
+		t, err := app.tournaments.Get(id)
+		if err != nil {
+			app.errorLog.Print(err)
+			continue
+		}
+		if _, err = app.scheduleReady(t); err != nil {
+			app.errorLog.Print(err)
+		}
+	}
+	app.sessionManager.Put(r, "flash", fmt.Sprintf("%d courts were closed and %d sessions were cancelled", len(ids), len(cancelled)))
+	http.Redirect(w, r, "/calendar", http.StatusSeeOther)
+}
+
+// Show all the courts with their booking grid, so that an admin can change
+// it
+func (app *application) showCourts(w http.ResponseWriter, r *http.Request) {
+	courts, err := app.courts.All()
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	app.render(w, r, "courts.page.tmpl", &templateData{Courts: courts})
+}
+
+// Change the booking grid of a court to the slot length and the durations of
+// the POSTed form. The sessions which are already booked are not changed
+func (app *application) setCourtGrid(w http.ResponseWriter, r *http.Request) {
+	id, ok := intParam(r, ":id")
+	if !ok {
+		app.notFound(w)
+		return
+	}
+	err := r.ParseForm()
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+	court, err := app.courts.Get(id)
+	if err == models.ErrNoRecord {
+		app.notFound(w)
+		return
+	} else if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	form := app.newForm(r, r.PostForm)
+	form.Required("slot", "durations")
+	form.PermittedValues("slot", minutes(models.SlotLengths)...)
+	form.PermittedMultiValues("durations", minutes(models.SessionDurations)...)
+	slotLength := time.Duration(form.GetInt("slot")) * time.Minute
+	durations := []time.Duration{}
+	for _, d := range models.SessionDurations {
+		if !contains(form.Values["durations"], strconv.Itoa(int(d/time.Minute))) {
+			continue
+		}
+		// a session has to end on the grid, so that the next one can start
+		// right after it
+		if slotLength > 0 && d%slotLength != 0 {
+			form.Errors.Add("durations", fmt.Sprintf("Every duration must be a multiple of %s", forms.HumanDuration(slotLength)))
+			break
+		}
+		durations = append(durations, d)
+	}
+	if !form.Valid() {
+		courts, err := app.courts.All()
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+		app.render(w, r, "courts.page.tmpl", &templateData{Court: court, Courts: courts, Form: form})
+		return
+	}
+	if err = app.courts.SetGrid(court.ID, slotLength, durations); err != nil {
+		app.serverError(w, err)
+		return
+	}
+	app.sessionManager.Put(r, "flash", fmt.Sprintf("Sessions on %s now start every %s", court.Name, forms.HumanDuration(slotLength)))
+	http.Redirect(w, r, "/admin/courts", http.StatusSeeOther)
+}
+
+// return durations as whole minutes in strings, so that they can be used as
+// the permitted values of a form field
+func minutes(durations []time.Duration) []string {
+	values := make([]string, 0, len(durations))
+	for _, d := range durations {
+		values = append(values, strconv.Itoa(int(d/time.Minute)))
+	}
+	return values
+}
+
+// Show the form to change an upcoming session, prefilled with its current
+// court, title, content and time slot
+func (app *application) editSessionForm(w http.ResponseWriter, r *http.Request) {
+	s, ok := app.editableSession(w, r)
+	if !ok {
+		return
+	}
+	courts, err := app.courts.All()
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	loc := app.userLocation(r)
+	app.render(w, r, "edit.page.tmpl", &templateData{
+		Courts:  courts,
+		Session: s,
+		Form: app.newForm(r, url.Values{
+			"court":   {strconv.Itoa(s.CourtID)},
+			"title":   {s.Title},
+			"content": {s.Content},
+			"start":   {s.Start.In(loc).Format(forms.DateTimeLayout)},
+			"end":     {s.End.In(loc).Format(forms.DateTimeLayout)},
+		}),
+	})
+}
+
+// Change an upcoming session after receiving a POST request. The new court
+// and time slot are validated like a new booking, the previous values are
+// kept in the history of the session. The other players are notified if the
+// session was moved, and its old slot is offered to the waitlist
+func (app *application) editSession(w http.ResponseWriter, r *http.Request) {
+	s, ok := app.editableSession(w, r)
+	if !ok {
+		return
+	}
+	err := r.ParseForm()
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+	courts, err := app.courts.All()
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	form := app.newForm(r, r.PostForm)
+	form.Required("court", "title", "content", "start", "end")
+	form.MaxLength("title", 100)
+	form.PermittedValues("court", courtIDs(courts)...)
+	form.ValidDateTime("start", "end")
+	form.FutureDateTime("start")
+	form.After("end", "start")
+	form.MaxDuration("start", "end", app.rules.maxDuration)
+	td := &templateData{Courts: courts, Session: s, Form: form}
+	if !form.Valid() {
+		app.render(w, r, "edit.page.tmpl", td)
+		return
+	}
+	courtID, _ := strconv.Atoi(form.Get("court"))
+	slot := app.formSlot(form)
+	moved := courtID != s.CourtID || !slot.Start.Equal(s.Start) || !slot.End.Equal(s.End)
+	// the new slot has to follow the same rules as a new booking, the slot
+	// of the session itself is free for it
+	var check *models.BookingCheck
+	if moved {
+		gridErrors(form, findCourt(courts, courtID), slot)
+		if !form.Valid() {
+			app.render(w, r, "edit.page.tmpl", td)
+			return
+		}
+		availability, err := app.schedule.Availability(slot.Start, slot.End)
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+		if reason := availability.Closed(courtID, slot); reason != "" {
+			form.Errors.Add("start", fmt.Sprintf("The court is closed at this time (%s)", reason))
+			app.render(w, r, "edit.page.tmpl", td)
+			return
+		}
+		check, ok, err = app.tierErrors(r, form, []models.Slot{slot}, s.ID)
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+		if !ok {
+			app.render(w, r, "edit.page.tmpl", td)
+			return
+		}
+	}
+	// the price is only computed again if the session was moved, otherwise
+	// it stays the price at which the session was booked
+	price := s.Price
+	if moved {
+		pricing, err := app.prices.Pricing()
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+		price = pricing.Price(courtID, slot, s.Guests)
+	}
+	changed := &models.Session{
+		ID:      s.ID,
+		CourtID: courtID,
+		Price:   price,
+		Title:   form.Get("title"),
+		Content: form.Get("content"),
+		Start:   slot.Start,
+		End:     slot.End,
+	}
+	user := app.authenticatedUser(r)
+	err = app.session.Update(changed, user.ID, check)
+	switch err {
+	case nil:
+	case models.ErrSlotTaken:
+		form.Errors.Add("start", "This court is already booked at this time")
+	case models.ErrCourtClosed:
+		form.Errors.Add("start", "The court is closed at this time")
+	case models.ErrOffGrid:
+		form.Errors.Add("start", "The session does not fit the booking grid of the court")
+	case models.ErrBookingRefused:
+		// the reasons were added to the form by the check
+	case models.ErrCoachUnavailable:
+		form.Errors.Add("start", fmt.Sprintf("%s is not available at this time", s.CoachName))
+	case models.ErrEquipmentUnavailable:
+		form.Errors.Add("start", "The rented equipment is not available at this time")
+	case models.ErrNoRecord:
+		// the session was cancelled or moved by another request in the
+		// meantime
+		app.notFound(w)
+		return
+	default:
+		app.serverError(w, err)
+		return
+	}
+	if !form.Valid() {
+		app.render(w, r, "edit.page.tmpl", td)
+		return
+	}
+	if moved {
+		// everyone else who will be on the court is told about the new slot.
+		// The session is already moved, a failed notification is only logged
+		participants, err := app.participants.ForSession(s.ID)
+		if err != nil {
+			app.errorLog.Print(err)
+		}
+		msg := fmt.Sprintf("%s moved the session of %s to %s", user.Name, humanDate(s.Start, app.location), humanDate(slot.Start, app.location))
+		for _, userID := range sessionRecipients(s, participants) {
+			if userID == user.ID {
+				continue
+			}
+			if _, err = app.notifications.Insert(userID, msg, fmt.Sprintf("/session/%d", s.ID)); err != nil {
+				app.errorLog.Print(err)
+			}
+		}
+		app.promoteWaitlist(s)
+	}
+	app.sessionManager.Put(r, "flash", "Tennis session was updated!")
+	http.Redirect(w, r, fmt.Sprintf("/session/%d", s.ID), http.StatusSeeOther)
+}
+
+// editableSession fetches the session of the :id URL parameter and checks
+// that the authenticated user can change it. If not, an error response is
+// sent and ok is false
+func (app *application) editableSession(w http.ResponseWriter, r *http.Request) (*models.Session, bool) {
+	id, ok := intParam(r, ":id")
+	if !ok {
+		app.notFound(w)
+		return nil, false
+	}
+	s, err := app.session.Get(id)
+	if err == models.ErrNoRecord {
+		app.notFound(w)
+		return nil, false
+	} else if err != nil {
+		app.serverError(w, err)
+		return nil, false
+	}
+	if !canEdit(app.authenticatedUser(r), s) {
+		app.sessionManager.Put(r, "flash", "Only the user who booked an upcoming session can change it")
+		http.Redirect(w, r, fmt.Sprintf("/session/%d", id), http.StatusSeeOther)
+		return nil, false
+	}
+	return s, true
+}
+
+// Show the sessions in which the authenticated user takes part, one page at a
+// time. The 'filter' query parameter selects the upcoming (default), past or
+// cancelled sessions
+func (app *application) showBookings(w http.ResponseWriter, r *http.Request) {
+	form := app.newForm(r, r.URL.Query())
+	if form.Get("filter") == "" {
+		form.Set("filter", models.BookingsUpcoming)
+	}
+	form.PermittedValues("filter", models.BookingsUpcoming, models.BookingsPast, models.BookingsCancelled)
+	form.IntRange("page", 1, maxBookingsPage)
+	if !form.Valid() {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+	page := form.GetInt("page")
+	if page < 1 {
+		page = 1
+	}
+	user := app.authenticatedUser(r)
+	// one more session than shown is fetched, to know if there is a next page
+	sessions, err := app.session.Bookings(user.ID, form.Get("filter"), time.Now(), bookingsPerPage+1, (page-1)*bookingsPerPage)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	more := len(sessions) > bookingsPerPage
+	if more {
+		sessions = sessions[:bookingsPerPage]
+	}
+	cancellable := map[int]bool{}
+	for _, s := range sessions {
+		cancellable[s.ID] = app.canCancel(user, s)
+	}
+	app.render(w, r, "bookings.page.tmpl", &templateData{
+		Cancellable: cancellable,
+		Form:        form,
+		Pagination:  paginate("/user/bookings", url.Values{"filter": {form.Get("filter")}}, page, more),
+		Sessions:    sessions,
+	})
 }
 
 // status check or uptime monitore of server
@@ -100,3 +100,35 @@ func TestPing2(t *testing.T) {
 		t.Errorf("expected body to equal %q", "OK")
 	}
 }
+
+// Test the handlers of the courts with the mocked courts: unknown courts are
+// not found and only authenticated users reach the admin pages
+func TestCourtHandlers(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		code     int
+		location string
+	}{
+		{name: "InvalidCourtFilter", urlPath: "/?court=abc", code: http.StatusNotFound},
+		{name: "UnknownCourtFilter", urlPath: "/?court=2", code: http.StatusNotFound},
+		{name: "InvalidCourtCalendar", urlPath: "/calendar/abc", code: http.StatusNotFound},
+		{name: "UnknownCourtCalendar", urlPath: "/calendar/2", code: http.StatusNotFound},
+		{name: "AdminCourtsAnonymous", urlPath: "/admin/courts", code: http.StatusFound, location: "/user/login"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, header, _ := ts.get(t, tt.urlPath)
+			if code != tt.code {
+				t.Errorf("expected %d; got %d", tt.code, code)
+			}
+			if location := header.Get("Location"); location != tt.location {
+				t.Errorf("expected location %q; got %q", tt.location, location)
+			}
+		})
+	}
+}
@@ -3,10 +3,15 @@ package main
 import (
 	"bytes"
 	"fmt"
+	"html/template"
 	"net/http"
+	"net/url"
 	"runtime/debug"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/erodrigufer/GoTennis/pkg/forms"
 	"github.com/erodrigufer/GoTennis/pkg/models"
 	"github.com/justinas/nosurf"
 )
@@ -31,6 +36,17 @@ func (app *application) addDefaultData(td *templateData, r *http.Request) *templ
 
 	// Add any flash message (if it exists) to the template data
 	td.Flash = app.sessionManager.PopString(r, "flash")
+	// Add the unread notifications of the authenticated user, they are
+	// displayed only once like the flash messages, and are marked as read by
+	// app.render once the page was sent. Failing to fetch them should not
+	// prevent the page from being rendered
+	if td.AuthenticatedUser != nil {
+		notifications, err := app.notifications.Unread(td.AuthenticatedUser.ID)
+		if err != nil {
+			app.errorLog.Print(err)
+		}
+		td.Notifications = notifications
+	}
 	return td
 }
 
@@ -51,15 +67,84 @@ func (app *application) render(w http.ResponseWriter, r *http.Request, name stri
 	// the client. Something like this can happen, when there is an error in a
 	// template, then the Execute() method will return an error
 	buf := new(bytes.Buffer)
+	// The times are rendered in the time zone of the user
+	ts, err := app.localTemplate(name, ts, app.userLocation(r))
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
 	// Execute the template set, passing in any dynamic data
-	err := ts.Execute(buf, app.addDefaultData(dynamicData, r))
+	td := app.addDefaultData(dynamicData, r)
+	err = ts.Execute(buf, td)
 	if err != nil {
 		app.serverError(w, err)
 		return // Do not send the template back to the client
 	}
 	// There was no error while executing/rendering the template, so send the
 	// whole template back to the client
-	buf.WriteTo(w)
+	if _, err = buf.WriteTo(w); err != nil {
+		app.errorLog.Print(err)
+		return
+	}
+	// the notifications were displayed, they are not shown again
+	if td.AuthenticatedUser != nil {
+		if err = app.notifications.MarkRead(td.AuthenticatedUser.ID, td.Notifications); err != nil {
+			app.errorLog.Print(err)
+		}
+	}
+}
+
+// localTemplate returns the template set ts of the page name with the time
+// functions of the time zone loc. The sets are cloned once per page and time
+// zone and then reused: the cached set of the page is never executed itself,
+// html/template does not allow to clone a template set after it was executed
+func (app *application) localTemplate(name string, ts *template.Template, loc *time.Location) (*template.Template, error) {
+	key := name + "|" + loc.String()
+	if local, ok := app.localTemplates.Load(key); ok {
+		return local.(*template.Template), nil
+	}
+	local, err := ts.Clone()
+	if err != nil {
+		return nil, err
+	}
+	local.Funcs(timeFunctions(loc))
+	// another request may have stored the same set in the meantime
+	stored, _ := app.localTemplates.LoadOrStore(key, local)
+	return stored.(*template.Template), nil
+}
+
+// userLocation returns the time zone of the authenticated user, or the time
+// zone of the club if the user did not choose one. The time zones are loaded
+// once and then kept by their name
+func (app *application) userLocation(r *http.Request) *time.Location {
+	user := app.authenticatedUser(r)
+	if user == nil || user.TimeZone == "" {
+		return app.location
+	}
+	if loc, ok := app.locations.Load(user.TimeZone); ok {
+		return loc.(*time.Location)
+	}
+	loc, err := time.LoadLocation(user.TimeZone)
+	if err != nil {
+		return app.location
+	}
+	app.locations.Store(user.TimeZone, loc)
+	return loc
+}
+
+// newForm returns a form holding the data, whose dates and times are in the
+// time zone of the authenticated user
+func (app *application) newForm(r *http.Request, data url.Values) *forms.Form {
+	form := forms.New(data)
+	form.Location = app.userLocation(r)
+	return form
+}
+
+// formSlot returns the time slot between the 'start' and 'end' fields of a
+// form, in the time zone of the club. The opening hours, the prices and the
+// rules of the tiers are defined in the time zone of the club
+func (app *application) formSlot(form *forms.Form) models.Slot {
+	return models.Slot{Start: form.GetTime("start").In(app.location), End: form.GetTime("end").In(app.location)}
 }
 
 // Send error message and stack trace to error logger
@@ -99,3 +184,228 @@ func (app *application) authenticatedUser(r *http.Request) *models.User {
 	}
 	return user
 }
+
+// return the ids of the courts as strings, so that they can be used as the
+// permitted values of a form field
+func courtIDs(courts []*models.Court) []string {
+	ids := make([]string, 0, len(courts))
+	for _, c := range courts {
+		ids = append(ids, strconv.Itoa(c.ID))
+	}
+	return ids
+}
+
+// findCourt returns the court with the given id, or nil if there is none
+func findCourt(courts []*models.Court, id int) *models.Court {
+	for _, c := range courts {
+		if c.ID == id {
+			return c
+		}
+	}
+	return nil
+}
+
+// gridErrors adds an error to the form if the slot does not start on the
+// booking grid of the court, or if its duration is not allowed on the court.
+// The grid starts at midnight in the time zone of the club
+func gridErrors(form *forms.Form, court *models.Court, slot models.Slot) {
+	if !court.OnGrid(slot.Start) {
+		form.Errors.Add("start", fmt.Sprintf("Sessions on this court start every %s, e.g. at %s",
+			forms.HumanDuration(court.SlotLength), humanTime(court.Snap(slot.Start), form.Location)))
+	}
+	if !court.AllowedDuration(slot.End.Sub(slot.Start)) {
+		durations := make([]string, 0, len(court.Durations))
+		for _, d := range court.Durations {
+			durations = append(durations, forms.HumanDuration(d))
+		}
+		form.Errors.Add("end", fmt.Sprintf("Sessions on this court last %s", strings.Join(durations, ", ")))
+	}
+}
+
+// Return the value of a positive integer URL parameter (like ':id'), ok is
+// false if the parameter is missing or is not a positive integer
+func intParam(r *http.Request, name string) (int, bool) {
+	id, err := strconv.Atoi(r.URL.Query().Get(name))
+	if err != nil || id < 1 {
+		return 0, false
+	}
+	return id, true
+}
+
+// guestRate reports if the bookings of the user are charged the guest rate,
+// which is the case for the members of the guest tier
+func guestRate(user *models.User) bool {
+	return user.Tier == models.TierGuest
+}
+
+// confirmedQuote reports if the user confirmed a booking at the given price,
+// in cents. The price is shown to the user before booking (the 'confirm'
+// field of the form holds the price which was shown), if it changed since
+// then the new price has to be confirmed again
+func confirmedQuote(form *forms.Form, price int) bool {
+	return form.Get("confirm") == strconv.Itoa(price)
+}
+
+// canCancel reports if the user is allowed to cancel the session now. Only
+// booked sessions which have not started yet can be cancelled by the user who
+// booked them (or by the coach giving the lesson) until the cancellation
+// cutoff before the start. Admins can cancel any session until it starts
+func (app *application) canCancel(user *models.User, s *models.Session) bool {
+	if user == nil || s.Cancelled() || !s.Upcoming() {
+		return false
+	}
+	if user.IsAdmin() {
+		return true
+	}
+	return (user.ID == s.UserID || user.ID == s.CoachID) && time.Until(s.Start) >= app.rules.cancelCutoff
+}
+
+// cancelRefusal returns a message explaining why the user cannot cancel a
+// session
+func (app *application) cancelRefusal(user *models.User, s *models.Session) string {
+	switch {
+	case !user.IsAdmin() && user.ID != s.UserID && user.ID != s.CoachID:
+		return "Only the user who booked this session can cancel it"
+	case s.Cancelled():
+		return "This session was already cancelled"
+	case !s.Upcoming():
+		return "This session already started"
+	default:
+		return fmt.Sprintf("Sessions can only be cancelled up to %s before they start", forms.HumanDuration(app.rules.cancelCutoff))
+	}
+}
+
+// canEdit reports if the user is allowed to change the session. Only booked
+// sessions which have not started yet can be changed, by the user who booked
+// them or by an admin
+func canEdit(user *models.User, s *models.Session) bool {
+	if user == nil || s.Cancelled() || !s.Upcoming() {
+		return false
+	}
+	return user.IsAdmin() || user.ID == s.UserID
+}
+
+// canInvite reports if the user is allowed to invite more players to the
+// session, given its participants. Only the user who booked an upcoming
+// session can invite players, as long as the session is not full
+func canInvite(user *models.User, s *models.Session, participants []*models.Participant) bool {
+	if user == nil || user.ID != s.UserID || s.Cancelled() || !s.Upcoming() {
+		return false
+	}
+	// the user who booked the session is also a player
+	players := 1
+	for _, p := range participants {
+		if p.Status != models.ParticipantDeclined {
+			players++
+		}
+	}
+	return players < s.MaxPlayers()
+}
+
+// canRecordResult reports if the user is allowed to record the result of the
+// session: it has to be over, and the user has to be one of its players or
+// an admin
+func canRecordResult(user *models.User, s *models.Session, participants []*models.Participant) bool {
+	if user == nil || s.Cancelled() || s.End.After(time.Now()) {
+		return false
+	}
+	if user.IsAdmin() || user.ID == s.UserID {
+		return true
+	}
+	for _, p := range participants {
+		if p.UserID == user.ID && p.Status == models.ParticipantAccepted {
+			return true
+		}
+	}
+	return false
+}
+
+// canConfirmResult reports if the user is allowed to confirm or reject the
+// result of the session: it has to be pending, and the user has to be
+// allowed to record results of the session without being the player who
+// reported it
+func canConfirmResult(user *models.User, s *models.Session, participants []*models.Participant, result *models.MatchResult) bool {
+	if !result.Pending() || user == nil || user.ID == result.ReportedBy {
+		return false
+	}
+	return canRecordResult(user, s, participants)
+}
+
+// resultPlayers returns the ids of the players of a session: the user who
+// booked it and the participants who accepted to play
+func resultPlayers(s *models.Session, participants []*models.Participant) []int {
+	players := []int{s.UserID}
+	for _, p := range participants {
+		if p.Status == models.ParticipantAccepted {
+			players = append(players, p.UserID)
+		}
+	}
+	return players
+}
+
+// singlesOpponent returns the id of the opponent of the user who booked a
+// singles session, 0 if the session is not a singles session or nobody
+// accepted to play it
+func singlesOpponent(s *models.Session, participants []*models.Participant) int {
+	if s.Kind != models.KindSingles {
+		return 0
+	}
+	for _, p := range participants {
+		if p.Status == models.ParticipantAccepted {
+			return p.UserID
+		}
+	}
+	return 0
+}
+
+// promoteWaitlist books the slot of a cancelled or rescheduled session for the
+// first user on its waitlist who is allowed to book it and notifies that
+// user. Errors are only logged, since the cancellation itself already
+// succeeded
+func (app *application) promoteWaitlist(s *models.Session) {
+	pricing, err := app.prices.Pricing()
+	if err != nil {
+		app.errorLog.Print(err)
+		return
+	}
+	// the price rules are defined in the time zone of the club
+	slot := models.Slot{Start: s.Start.In(app.location), End: s.End.In(app.location)}
+	// the users on the waitlist are bound to the rules of their tier like
+	// for any other booking
+	check := func(e *models.WaitlistEntry) (*models.BookingCheck, error) {
+		user, err := app.users.Get(e.UserID)
+		if err != nil {
+			return nil, err
+		}
+		return app.bookingCheck(user, nil, []models.Slot{{Start: e.Start.In(app.location), End: e.End.In(app.location)}}, 0)
+	}
+	e, id, err := app.waitlist.Promote(s.CourtID, slot, pricing, check)
+	if err != nil {
+		app.errorLog.Print(err)
+		return
+	}
+	// nobody was waiting for the slot
+	if e == nil {
+		return
+	}
+	msg := fmt.Sprintf("A slot you were waiting for became free, %s on %s was booked for you!", s.CourtName, humanDate(e.Start, app.location))
+	if _, err = app.notifications.Insert(e.UserID, msg, fmt.Sprintf("/session/%d", id)); err != nil {
+		app.errorLog.Print(err)
+	}
+}
+
+// sessionRecipients returns the ids of the users who are told when the
+// session is moved or rained out: everyone who would be on the court, except
+// for the players who declined their invitation
+func sessionRecipients(s *models.Session, participants []*models.Participant) []int {
+	notified := []int{s.UserID}
+	if s.CoachID != 0 {
+		notified = append(notified, s.CoachID)
+	}
+	for _, p := range participants {
+		if p.Status != models.ParticipantDeclined {
+			notified = append(notified, p.UserID)
+		}
+	}
+	return notified
+}
@@ -0,0 +1,252 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/erodrigufer/GoTennis/pkg/models"
+)
+
+func TestCanCancel(t *testing.T) {
+	app := newTestApplication(t)
+	app.rules.cancelCutoff = 2 * time.Hour
+
+	member := &models.User{ID: 1, Role: models.RoleMember}
+	admin := &models.User{ID: 2, Role: models.RoleAdmin}
+	other := &models.User{ID: 3, Role: models.RoleMember}
+	coach := &models.User{ID: 4, Role: models.RoleCoach}
+	// session returns a lesson booked by the member starting after d
+	session := func(d time.Duration, status string) *models.Session {
+		return &models.Session{
+			UserID:  member.ID,
+			CoachID: coach.ID,
+			Start:   time.Now().Add(d),
+			End:     time.Now().Add(d + time.Hour),
+			Status:  status,
+		}
+	}
+	tests := []struct {
+		name     string
+		user     *models.User
+		session  *models.Session
+		expected bool
+	}{
+		{name: "Anonymous", user: nil, session: session(24*time.Hour, models.StatusBooked), expected: false},
+		{name: "BeforeCutoff", user: member, session: session(24*time.Hour, models.StatusBooked), expected: true},
+		{name: "NotOwner", user: other, session: session(24*time.Hour, models.StatusBooked), expected: false},
+		{name: "Coach", user: coach, session: session(24*time.Hour, models.StatusBooked), expected: true},
+		{name: "CoachAfterCutoff", user: coach, session: session(time.Hour, models.StatusBooked), expected: false},
+		{name: "AfterCutoff", user: member, session: session(time.Hour, models.StatusBooked), expected: false},
+		{name: "AdminAfterCutoff", user: admin, session: session(time.Hour, models.StatusBooked), expected: true},
+		{name: "Started", user: admin, session: session(-time.Minute, models.StatusBooked), expected: false},
+		{name: "Cancelled", user: member, session: session(24*time.Hour, models.StatusCancelled), expected: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ok := app.canCancel(tt.user, tt.session)
+			if ok != tt.expected {
+				t.Errorf("expected %t; got %t", tt.expected, ok)
+			}
+		})
+	}
+}
+
+func TestCanEdit(t *testing.T) {
+	owner := &models.User{ID: 1, Role: models.RoleMember}
+	admin := &models.User{ID: 2, Role: models.RoleAdmin}
+	other := &models.User{ID: 3, Role: models.RoleMember}
+	// session returns a session booked by the owner starting after d
+	session := func(d time.Duration, status string) *models.Session {
+		return &models.Session{
+			UserID: owner.ID,
+			Start:  time.Now().Add(d),
+			End:    time.Now().Add(d + time.Hour),
+			Status: status,
+		}
+	}
+	tests := []struct {
+		name     string
+		user     *models.User
+		session  *models.Session
+		expected bool
+	}{
+		{name: "Anonymous", user: nil, session: session(time.Hour, models.StatusBooked), expected: false},
+		{name: "Owner", user: owner, session: session(time.Hour, models.StatusBooked), expected: true},
+		{name: "Admin", user: admin, session: session(time.Hour, models.StatusBooked), expected: true},
+		{name: "NotOwner", user: other, session: session(time.Hour, models.StatusBooked), expected: false},
+		{name: "Started", user: owner, session: session(-time.Minute, models.StatusBooked), expected: false},
+		{name: "Cancelled", user: admin, session: session(time.Hour, models.StatusCancelled), expected: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ok := canEdit(tt.user, tt.session)
+			if ok != tt.expected {
+				t.Errorf("expected %t; got %t", tt.expected, ok)
+			}
+		})
+	}
+}
+
+func TestCanInvite(t *testing.T) {
+	owner := &models.User{ID: 1, Role: models.RoleMember}
+	other := &models.User{ID: 2, Role: models.RoleMember}
+	session := func(kind string) *models.Session {
+		return &models.Session{
+			UserID: owner.ID,
+			Kind:   kind,
+			Start:  time.Now().Add(24 * time.Hour),
+			End:    time.Now().Add(25 * time.Hour),
+			Status: models.StatusBooked,
+		}
+	}
+	invited := &models.Participant{UserID: 2, Status: models.ParticipantInvited}
+	declined := &models.Participant{UserID: 3, Status: models.ParticipantDeclined}
+	tests := []struct {
+		name         string
+		user         *models.User
+		session      *models.Session
+		participants []*models.Participant
+		expected     bool
+	}{
+		{name: "Anonymous", user: nil, session: session(models.KindSingles), expected: false},
+		{name: "NotOwner", user: other, session: session(models.KindSingles), expected: false},
+		{name: "EmptySingles", user: owner, session: session(models.KindSingles), expected: true},
+		{name: "FullSingles", user: owner, session: session(models.KindSingles), participants: []*models.Participant{invited}, expected: false},
+		{name: "DeclinedSingles", user: owner, session: session(models.KindSingles), participants: []*models.Participant{declined}, expected: true},
+		{name: "Doubles", user: owner, session: session(models.KindDoubles), participants: []*models.Participant{invited}, expected: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ok := canInvite(tt.user, tt.session, tt.participants)
+			if ok != tt.expected {
+				t.Errorf("expected %t; got %t", tt.expected, ok)
+			}
+		})
+	}
+}
+
+func TestCanRecordResult(t *testing.T) {
+	owner := &models.User{ID: 1, Role: models.RoleMember}
+	player := &models.User{ID: 2, Role: models.RoleMember}
+	other := &models.User{ID: 3, Role: models.RoleMember}
+	admin := &models.User{ID: 4, Role: models.RoleAdmin}
+	// session returns a session booked by the owner which ended d ago
+	session := func(d time.Duration, status string) *models.Session {
+		return &models.Session{
+			UserID: owner.ID,
+			Start:  time.Now().Add(-d - time.Hour),
+			End:    time.Now().Add(-d),
+			Status: status,
+		}
+	}
+	participants := []*models.Participant{{UserID: player.ID, Status: models.ParticipantAccepted}}
+	tests := []struct {
+		name     string
+		user     *models.User
+		session  *models.Session
+		expected bool
+	}{
+		{name: "Anonymous", user: nil, session: session(time.Hour, models.StatusBooked), expected: false},
+		{name: "Owner", user: owner, session: session(time.Hour, models.StatusBooked), expected: true},
+		{name: "Participant", user: player, session: session(time.Hour, models.StatusBooked), expected: true},
+		{name: "NotPlaying", user: other, session: session(time.Hour, models.StatusBooked), expected: false},
+		{name: "Admin", user: admin, session: session(time.Hour, models.StatusBooked), expected: true},
+		{name: "NotOver", user: owner, session: session(-time.Hour, models.StatusBooked), expected: false},
+		{name: "Cancelled", user: owner, session: session(time.Hour, models.StatusCancelled), expected: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ok := canRecordResult(tt.user, tt.session, participants)
+			if ok != tt.expected {
+				t.Errorf("expected %t; got %t", tt.expected, ok)
+			}
+		})
+	}
+}
+
+func TestCanConfirmResult(t *testing.T) {
+	owner := &models.User{ID: 1, Role: models.RoleMember}
+	player := &models.User{ID: 2, Role: models.RoleMember}
+	other := &models.User{ID: 3, Role: models.RoleMember}
+	admin := &models.User{ID: 4, Role: models.RoleAdmin}
+	s := &models.Session{
+		UserID: owner.ID,
+		Start:  time.Now().Add(-2 * time.Hour),
+		End:    time.Now().Add(-time.Hour),
+		Status: models.StatusBooked,
+	}
+	participants := []*models.Participant{{UserID: player.ID, Status: models.ParticipantAccepted}}
+	pending := &models.MatchResult{Status: models.ResultPending, ReportedBy: owner.ID}
+	confirmed := &models.MatchResult{Status: models.ResultConfirmed, ReportedBy: owner.ID, ConfirmedBy: player.ID}
+	tests := []struct {
+		name     string
+		user     *models.User
+		result   *models.MatchResult
+		expected bool
+	}{
+		{name: "Anonymous", user: nil, result: pending, expected: false},
+		{name: "Reporter", user: owner, result: pending, expected: false},
+		{name: "Opponent", user: player, result: pending, expected: true},
+		{name: "NotPlaying", user: other, result: pending, expected: false},
+		{name: "Admin", user: admin, result: pending, expected: true},
+		{name: "Confirmed", user: player, result: confirmed, expected: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ok := canConfirmResult(tt.user, s, participants, tt.result)
+			if ok != tt.expected {
+				t.Errorf("expected %t; got %t", tt.expected, ok)
+			}
+		})
+	}
+}
+
+func TestSinglesOpponent(t *testing.T) {
+	singles := &models.Session{UserID: 1, Kind: models.KindSingles}
+	doubles := &models.Session{UserID: 1, Kind: models.KindDoubles}
+	accepted := &models.Participant{UserID: 2, Status: models.ParticipantAccepted}
+	declined := &models.Participant{UserID: 3, Status: models.ParticipantDeclined}
+	tests := []struct {
+		name         string
+		session      *models.Session
+		participants []*models.Participant
+		expected     int
+	}{
+		{name: "Singles", session: singles, participants: []*models.Participant{declined, accepted}, expected: 2},
+		{name: "NobodyAccepted", session: singles, participants: []*models.Participant{declined}, expected: 0},
+		{name: "Doubles", session: doubles, participants: []*models.Participant{accepted}, expected: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			id := singlesOpponent(tt.session, tt.participants)
+			if id != tt.expected {
+				t.Errorf("expected %d; got %d", tt.expected, id)
+			}
+		})
+	}
+}
+
+func TestSessionRecipients(t *testing.T) {
+	invited := &models.Participant{UserID: 2, Status: models.ParticipantInvited}
+	accepted := &models.Participant{UserID: 3, Status: models.ParticipantAccepted}
+	declined := &models.Participant{UserID: 4, Status: models.ParticipantDeclined}
+	tests := []struct {
+		name         string
+		session      *models.Session
+		participants []*models.Participant
+		expected     []int
+	}{
+		{name: "Alone", session: &models.Session{UserID: 1}, expected: []int{1}},
+		{name: "Participants", session: &models.Session{UserID: 1}, participants: []*models.Participant{invited, declined, accepted}, expected: []int{1, 2, 3}},
+		{name: "Lesson", session: &models.Session{UserID: 1, CoachID: 5}, expected: []int{1, 5}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := sessionRecipients(tt.session, tt.participants)
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("expected %v; got %v", tt.expected, got)
+			}
+		})
+	}
+}
@@ -8,14 +8,16 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"sync"
 	"time"
+	_ "time/tzdata" // embed the time zone database, in case it is missing on the server
 
+	"github.com/erodrigufer/GoTennis/pkg/models"
 	"github.com/erodrigufer/GoTennis/pkg/models/mysql"
 
-	_ "github.com/go-sql-driver/mysql" // the driver's init() function must be
-	// run so that it can register itself with "database/sql" nothing else is
-	// actually used from this packet, so if no underscore would be present the
-	// Go compiler would bring up an error
+	// the driver's init() function registers it with "database/sql", the
+	// package itself is only used to parse the DSN
+	driver "github.com/go-sql-driver/mysql"
 	"github.com/golangcollege/sessions" // session manager
 )
 
@@ -28,12 +30,33 @@ var contextKeyUser = contextKey("user")
 
 // store all flag-parseable config values in this struct
 type configValues struct {
-	addr   string // address where the server is listening
-	dsn    string // information to open a connection pool on a database
-	secret string // secret used to encrypt information of sessions
+	addr    string // address where the server is listening
+	dsn     string // information to open a connection pool on a database
+	secret  string // secret used to encrypt information of sessions
+	checkIn string // secret used to sign the check-in URLs
+	tz      string // name of the time zone of the club
+	rules   bookingRules
+	ladder  ladderRules
+	quota   models.Quota // limits of the default tier, only applied if set by flags
 	//StaticDir string
 }
 
+// booking rules of the club, which are enforced when a session is created.
+// The limits of every member are set by the membership tier of the member
+type bookingRules struct {
+	maxDuration  time.Duration // longest time slot that can be booked
+	cancelCutoff time.Duration // sessions cannot be cancelled later than this before they start
+	checkIn      time.Duration // players can check in this long before and after the start of a session
+	prime        primeTime     // daily time band counted by the prime-time quota
+	penalty      penaltyRules  // suspensions of the members who do not show up
+}
+
+// rules of the singles ladder of the club
+type ladderRules struct {
+	reach int     // how many rungs above them players can challenge
+	k     float64 // largest change of a rating caused by a single match
+}
+
 // handle application-wide dependencies in this struct
 // this dependencies are then 'injected' to the different handlers,
 // by defining the handlers as methods to this struct
@@ -41,12 +64,65 @@ type configValues struct {
 // just defining these dependencies as global would not make the code easier to
 // unit-test
 type application struct {
+	checkInKey     []byte                        // key signing the check-in URLs of the sessions
+	coaches        *mysql.CoachModel             // availability and lessons of the coaches (db)
+	courts         courtStore                    // courts of the club (db)
+	equipment      *mysql.EquipmentModel         // equipment which can be rented (db)
 	errorLog       *log.Logger                   // error log handler
 	infoLog        *log.Logger                   // info log handler
+	ladder         *mysql.LadderModel            // ratings of the singles ladder (db)
+	ladderRules    ladderRules                   // rules of the singles ladder
+	localTemplates sync.Map                      // template sets with the time functions of a time zone, by page and time zone
+	location       *time.Location                // time zone of the club
+	locations      sync.Map                      // time zones of the users, by their name
+	notifications  *mysql.NotificationModel      // messages for the users (db)
+	participants   *mysql.ParticipantModel       // players invited to the sessions (db)
+	prices         *mysql.PriceModel             // price rules of the courts (db)
+	results        *mysql.ResultModel            // results of the played sessions (db)
+	rules          bookingRules                  // booking rules of the club
+	schedule       *mysql.ScheduleModel          // opening hours and blackouts (db)
+	series         *mysql.SeriesModel            // series of repeated sessions (db)
 	sessionManager *sessions.Session             // session manager
 	session        *mysql.SessionModel           // db for application
 	templateCache  map[string]*template.Template // Cache map with html templates
+	tiers          *mysql.TierModel              // membership tiers and their booking rules (db)
+	tournaments    *mysql.TournamentModel        // tournaments and their draws (db)
 	users          *mysql.UserModel              // user model inside users table (db)
+	waitlist       *mysql.WaitlistModel          // users waiting for a booked slot (db)
+}
+
+// seedQuota overwrites the quota of the full tier with the deprecated quota
+// flags which were set on the command line, the flags which were not set keep
+// the quota stored in the db
+func (app *application) seedQuota(quota models.Quota) error {
+	set := map[string]bool{}
+	flag.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	if !set["max-active"] && !set["max-weekly"] && !set["max-weekly-prime"] {
+		return nil
+	}
+	tier, err := app.tiers.Get(models.TierFull)
+	if err != nil {
+		return err
+	}
+	if set["max-active"] {
+		tier.Quota.MaxActive = quota.MaxActive
+	}
+	if set["max-weekly"] {
+		tier.Quota.MaxWeekly = quota.MaxWeekly
+	}
+	if set["max-weekly-prime"] {
+		tier.Quota.MaxWeeklyPrime = quota.MaxWeeklyPrime
+	}
+	app.infoLog.Printf("The quota flags are deprecated, the quota of the %s tier was set to them, use /admin/tiers instead", tier.Name)
+	return app.tiers.Update(tier)
+}
+
+// courtStore is implemented by mysql.CourtModel, and by mock.CourtModel to
+// test the handlers of the courts without a db
+type courtStore interface {
+	Get(id int) (*models.Court, error)
+	All() ([]*models.Court, error)
+	SetGrid(id int, slotLength time.Duration, durations []time.Duration) error
 }
 
 func main() {
@@ -62,6 +138,28 @@ func main() {
 	// Session secret (a random key) used to encrypt and authenticate session
 	// cookies. It should be 32 bytes long
 	flag.StringVar(&cfg.secret, "secret", "s6Ndh+pPbnzHbS*+9Pk8qGWhTzbpa@ge", "Session's secret key to encrypt and authenticate session cookies")
+	// the check-in URLs are signed with their own key, which is derived from
+	// the session secret if it is not set
+	flag.StringVar(&cfg.checkIn, "checkin-secret", "", "Secret key to sign the check-in URLs of the sessions (derived from the session secret if empty)")
+	// the times are stored at UTC in the db, they are shown and entered in the
+	// time zone of the club, unless users choose their own time zone
+	flag.StringVar(&cfg.tz, "timezone", "UTC", "Time zone of the club, like Europe/Berlin")
+	flag.DurationVar(&cfg.rules.maxDuration, "max-duration", 2*time.Hour, "Maximum duration of a tennis session")
+	flag.DurationVar(&cfg.rules.cancelCutoff, "cancel-cutoff", 2*time.Hour, "Minimum time before its start to cancel a tennis session (admins are exempted)")
+	flag.DurationVar(&cfg.rules.checkIn, "checkin-window", 15*time.Minute, "How long before and after the start of a tennis session players can check in")
+	flag.DurationVar(&cfg.rules.prime.start, "prime-start", 17*time.Hour, "Start of the prime time, as offset from midnight")
+	flag.DurationVar(&cfg.rules.prime.end, "prime-end", 21*time.Hour, "End of the prime time, as offset from midnight")
+	flag.IntVar(&cfg.rules.penalty.limit, "noshow-limit", 3, "No-shows within the no-show window which suspend the booking rights of a member (0 disables the suspensions)")
+	flag.DurationVar(&cfg.rules.penalty.window, "noshow-window", 30*24*time.Hour, "Rolling window in which the no-shows of a member are counted")
+	flag.DurationVar(&cfg.rules.penalty.ban, "noshow-ban", 14*24*time.Hour, "How long the booking rights of a member are suspended")
+	// the quotas are set per membership tier at /admin/tiers, these flags
+	// are kept so that existing deployments keep their limits, if given they
+	// overwrite the quota of the default (full) tier at startup
+	flag.IntVar(&cfg.quota.MaxActive, "max-active", 3, "Deprecated: maximum number of upcoming bookings of the full tier, a series counts as one (0 disables the limit)")
+	flag.DurationVar(&cfg.quota.MaxWeekly, "max-weekly", 4*time.Hour, "Deprecated: maximum playing time per week of the full tier (0 disables the limit)")
+	flag.IntVar(&cfg.quota.MaxWeeklyPrime, "max-weekly-prime", 2, "Deprecated: maximum number of prime-time sessions per week of the full tier (0 disables the limit)")
+	flag.IntVar(&cfg.ladder.reach, "ladder-reach", 3, "How many rungs above them players of the ladder can challenge")
+	flag.Float64Var(&cfg.ladder.k, "elo-k", 32, "Largest change of an Elo rating caused by a single match")
 	flag.Parse()
 
 	// Create a logger for INFO messages, the prefix "INFO" and a tab will be
@@ -73,6 +171,11 @@ func main() {
 	// display the file's name and line number for the error
 	errorLog := log.New(os.Stderr, "ERROR\t", log.Ldate|log.Ltime|log.Lshortfile)
 
+	location, err := time.LoadLocation(cfg.tz)
+	if err != nil {
+		errorLog.Fatal(err)
+	}
+
 	// open a connection to a db connection pool
 	db, err := connectDBpool(cfg.dsn)
 	if err != nil {
@@ -95,17 +198,48 @@ func main() {
 	sessionManager.SameSite = http.SameSiteStrictMode // read more about this on
 	// page 387 of Let's Go
 
+	checkInKey := []byte(cfg.checkIn)
+	if cfg.checkIn == "" {
+		checkInKey = deriveCheckInKey(cfg.secret)
+	}
+
 	// Initialize an instance of application containing the application-wide
 	// dependencies
 	app := &application{
+		checkInKey:     checkInKey,
+		coaches:        &mysql.CoachModel{DB: db},
+		courts:         &mysql.CourtModel{DB: db},
+		equipment:      &mysql.EquipmentModel{DB: db},
 		errorLog:       errorLog,
 		infoLog:        infoLog,
+		ladder:         &mysql.LadderModel{DB: db},
+		ladderRules:    cfg.ladder,
+		location:       location,
+		notifications:  &mysql.NotificationModel{DB: db},
+		participants:   &mysql.ParticipantModel{DB: db},
+		prices:         &mysql.PriceModel{DB: db},
+		results:        &mysql.ResultModel{DB: db},
+		rules:          cfg.rules,
+		schedule:       &mysql.ScheduleModel{DB: db},
+		series:         &mysql.SeriesModel{DB: db},
 		session:        &mysql.SessionModel{DB: db},
 		sessionManager: sessionManager,
 		templateCache:  templateCache,
+		tiers:          &mysql.TierModel{DB: db},
+		tournaments:    &mysql.TournamentModel{DB: db},
 		users:          &mysql.UserModel{DB: db},
+		waitlist:       &mysql.WaitlistModel{DB: db},
 	}
 
+	// the deprecated quota flags which were set seed the default tier
+	if err = app.seedQuota(cfg.quota); err != nil {
+		errorLog.Fatal(err)
+	}
+
+	// sessions which ended without a check-in are marked as no-shows in the
+	// background
+	go app.markNoShows(time.Minute)
+
 	// Store the non-default TLS configuration settings
 	tlsConfig := &tls.Config{
 		PreferServerCipherSuites: true,
@@ -145,8 +279,19 @@ func main() {
 // Troubleshooting: if the db service is not correctly active,
 // then running `ss -at` will not show the mysql db listening on localhost
 // at port `mysql`
+// The options which the models rely on are always set, whatever the DSN says:
+// parseTime converts SQL DATETIME fields to Go time.Time objects, and
+// clientFoundRows makes an UPDATE report the matched rows instead of the
+// changed rows, so that saving unchanged values is not mistaken for a missing
+// record
 func connectDBpool(dsn string) (*sql.DB, error) {
-	db, err := sql.Open("mysql", dsn)
+	cfg, err := driver.ParseDSN(dsn)
+	if err != nil {
+		return nil, err
+	}
+	cfg.ParseTime = true
+	cfg.ClientFoundRows = true
+	db, err := sql.Open("mysql", cfg.FormatDSN())
 	if err != nil {
 		return nil, err
 	}
@@ -84,6 +84,30 @@ func (app *application) requireAuthenticatedUser(next http.Handler) http.Handler
 	})
 }
 
+// requireAdmin only lets the admins of the club through, it has to be
+// chained after requireAuthenticatedUser
+func (app *application) requireAdmin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !app.authenticatedUser(r).IsAdmin() {
+			app.clientError(w, http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// requireCoach only lets the coaches of the club through, it has to be
+// chained after requireAuthenticatedUser
+func (app *application) requireCoach(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !app.authenticatedUser(r).IsCoach() {
+			app.clientError(w, http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
 // Anti-CSRF middleware with a customized cookie with Secure, Path and HttpOnly
 // flags set
 func noSurf(next http.Handler) http.Handler {
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"time"
+
+	"github.com/erodrigufer/GoTennis/pkg/models"
+)
+
+// penaltyRules suspend the booking rights of the members who repeatedly do
+// not show up to the sessions they booked
+type penaltyRules struct {
+	limit  int           // no-shows within the window which suspend a member, 0 disables the suspensions
+	window time.Duration // rolling window in which the no-shows are counted
+	ban    time.Duration // how long the booking rights are suspended
+}
+
+// since returns the time after which a no-show has to end to still cause a
+// suspension at the time now
+func (p penaltyRules) since(now time.Time) time.Time {
+	return now.Add(-p.window - p.ban)
+}
+
+// suspendedUntil returns the end of the suspension of a member given the
+// no-shows of the member ordered by their end time, or the zero time if the
+// member is not suspended at the time now. A suspension starts at the end of
+// the no-show which reaches the limit within the window
+func (p penaltyRules) suspendedUntil(noShows []*models.Session, now time.Time) time.Time {
+	var until time.Time
+	if p.limit <= 0 {
+		return until
+	}
+	for i, s := range noShows {
+		n := 0
+		for _, prev := range noShows[:i+1] {
+			if prev.End.After(s.End.Add(-p.window)) {
+				n++
+			}
+		}
+		if n >= p.limit && s.End.Add(p.ban).After(until) {
+			until = s.End.Add(p.ban)
+		}
+	}
+	if !until.After(now) {
+		return time.Time{}
+	}
+	return until
+}
+
+// suspensions returns the end of the suspension of every suspended member.
+// If userID is not 0, only that member is taken into account
+func (app *application) suspensions(userID int) (map[int]time.Time, error) {
+	now := time.Now()
+	noShows, err := app.session.NoShows(userID, app.rules.penalty.since(now))
+	if err != nil {
+		return nil, err
+	}
+	byUser := map[int][]*models.Session{}
+	for _, s := range noShows {
+		byUser[s.UserID] = append(byUser[s.UserID], s)
+	}
+	suspended := map[int]time.Time{}
+	for id, sessions := range byUser {
+		if until := app.rules.penalty.suspendedUntil(sessions, now); !until.IsZero() {
+			suspended[id] = until
+		}
+	}
+	return suspended, nil
+}
+
+// Players can flag a session as a no-show until this long after its end
+const noShowFlagPeriod = 24 * time.Hour
+
+// canFlagNoShow reports if the user is allowed to flag the session as a
+// no-show at the time now: it has to have started without a check-in, and
+// the user has to be an admin or one of the other players of the session.
+// Sessions without any check-in are marked automatically when they end, the
+// other players can flag them earlier, until shortly after their end. A
+// no-show which was cleared by an admin cannot be flagged again
+func canFlagNoShow(user *models.User, s *models.Session, participants []*models.Participant, now time.Time) bool {
+	if user == nil || s.Cancelled() || s.NoShow || s.NoShowClearedBy != 0 ||
+		!s.CheckedIn.IsZero() || now.Before(s.Start) {
+		return false
+	}
+	if user.IsAdmin() {
+		return true
+	}
+	if now.After(s.End.Add(noShowFlagPeriod)) {
+		return false
+	}
+	if user.ID == s.CoachID && user.ID != s.UserID {
+		return true
+	}
+	for _, p := range participants {
+		if p.UserID == user.ID && p.Status == models.ParticipantAccepted {
+			return true
+		}
+	}
+	return false
+}
+
+// canClearNoShow reports if the user is allowed to clear the no-show of the
+// session, which only admins can do
+func canClearNoShow(user *models.User, s *models.Session) bool {
+	return user != nil && user.IsAdmin() && s.NoShow
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/erodrigufer/GoTennis/pkg/models"
+)
+
+func TestSuspendedUntil(t *testing.T) {
+	day := 24 * time.Hour
+	now := time.Date(2022, 5, 30, 12, 0, 0, 0, time.UTC)
+	p := penaltyRules{limit: 3, window: 30 * day, ban: 14 * day}
+	// noShows returns sessions which ended the given number of days before now
+	noShows := func(daysAgo ...int) []*models.Session {
+		sessions := []*models.Session{}
+		for _, d := range daysAgo {
+			end := now.Add(-time.Duration(d) * day)
+			sessions = append(sessions, &models.Session{Start: end.Add(-time.Hour), End: end})
+		}
+		return sessions
+	}
+
+	tests := []struct {
+		name     string
+		rules    penaltyRules
+		noShows  []*models.Session
+		expected time.Time
+	}{
+		{name: "NoNoShows", rules: p, noShows: noShows(), expected: time.Time{}},
+		{name: "BelowTheLimit", rules: p, noShows: noShows(20, 10), expected: time.Time{}},
+		{name: "LimitReached", rules: p, noShows: noShows(20, 10, 2), expected: now.Add(12 * day)},
+		{name: "OutsideOfTheWindow", rules: p, noShows: noShows(45, 20, 2), expected: time.Time{}},
+		{name: "SuspensionOver", rules: p, noShows: noShows(40, 30, 20), expected: time.Time{}},
+		{name: "LatestSuspension", rules: p, noShows: noShows(25, 20, 15, 5), expected: now.Add(9 * day)},
+		{name: "SuspensionsDisabled", rules: penaltyRules{window: 30 * day, ban: 14 * day}, noShows: noShows(20, 10, 2), expected: time.Time{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.rules.suspendedUntil(tt.noShows, now)
+			if !got.Equal(tt.expected) {
+				t.Errorf("expected %v; got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestCanFlagNoShow(t *testing.T) {
+	start := time.Date(2022, 5, 16, 10, 0, 0, 0, time.UTC)
+	s := &models.Session{UserID: 1, Start: start, End: start.Add(time.Hour)}
+	checkedIn := &models.Session{UserID: 1, Start: start, End: start.Add(time.Hour), CheckedIn: start}
+	cleared := &models.Session{UserID: 1, Start: start, End: start.Add(time.Hour), NoShowClearedBy: 3}
+	participants := []*models.Participant{{UserID: 2, Status: models.ParticipantAccepted}}
+	player := &models.User{ID: 2}
+	admin := &models.User{ID: 3, Role: models.RoleAdmin}
+	during := start.Add(30 * time.Minute)
+	late := start.Add(time.Hour + noShowFlagPeriod + time.Minute)
+
+	tests := []struct {
+		name     string
+		user     *models.User
+		session  *models.Session
+		now      time.Time
+		expected bool
+	}{
+		{name: "Player", user: player, session: s, now: during, expected: true},
+		{name: "Owner", user: &models.User{ID: 1}, session: s, now: during, expected: false},
+		{name: "NotStarted", user: player, session: s, now: start.Add(-time.Minute), expected: false},
+		{name: "CheckedIn", user: player, session: checkedIn, now: during, expected: false},
+		{name: "Cleared", user: player, session: cleared, now: during, expected: false},
+		{name: "PlayerTooLate", user: player, session: s, now: late, expected: false},
+		{name: "AdminTooLate", user: admin, session: s, now: late, expected: true},
+		{name: "AdminCleared", user: admin, session: cleared, now: during, expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := canFlagNoShow(tt.user, tt.session, participants, tt.now)
+			if got != tt.expected {
+				t.Errorf("expected %t; got %t", tt.expected, got)
+			}
+		})
+	}
+}
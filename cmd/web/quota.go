@@ -0,0 +1,185 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/erodrigufer/GoTennis/pkg/forms"
+	"github.com/erodrigufer/GoTennis/pkg/models"
+)
+
+// primeTime is the daily time band in which the courts are most wanted, its
+// start and end are the offsets from midnight (e.g. 17*time.Hour for 17:00)
+type primeTime struct {
+	start time.Duration
+	end   time.Duration
+}
+
+// overlaps reports if the slot overlaps with the prime-time band of the day on
+// which it starts. The band is taken on the wall clock, so that it does not
+// move on the days on which daylight saving time starts or ends
+func (p primeTime) overlaps(slot models.Slot) bool {
+	if p.end <= p.start {
+		return false
+	}
+	clock := func(offset time.Duration) time.Time {
+		return time.Date(slot.Start.Year(), slot.Start.Month(), slot.Start.Day(), 0, 0, int(offset.Seconds()), 0, slot.Start.Location())
+	}
+	return slot.Start.Before(clock(p.end)) && slot.End.After(clock(p.start))
+}
+
+// checkQuota returns a message for every limit of the quota which would be
+// exceeded if the user booked the slots, given the sessions already booked by
+// the user. The session excludeID is not taken into account (e.g. when it is
+// being edited), the slots of a new series count as a single booking
+func checkQuota(q models.Quota, prime primeTime, booked []*models.Session, slots []models.Slot, excludeID int, now time.Time) []string {
+	msgs := []string{}
+	sessions := make([]*models.Session, 0, len(booked))
+	for _, s := range booked {
+		if s.ID != excludeID {
+			sessions = append(sessions, s)
+		}
+	}
+
+	if q.MaxActive > 0 {
+		// all the sessions of a series are a single booking
+		bookings := map[string]bool{}
+		for _, s := range sessions {
+			if !s.End.After(now) {
+				continue
+			}
+			if s.SeriesID != 0 {
+				bookings[fmt.Sprintf("series-%d", s.SeriesID)] = true
+			} else {
+				bookings[fmt.Sprintf("session-%d", s.ID)] = true
+			}
+		}
+		if len(bookings)+1 > q.MaxActive {
+			msgs = append(msgs, fmt.Sprintf("You already have %d upcoming bookings (maximum is %d)", len(bookings), q.MaxActive))
+		}
+	}
+
+	// the weekly limits are checked for every week in which one of the slots
+	// takes place, counting the sessions already booked in that week and the
+	// new slots of that week. The weeks and the prime time are taken in the
+	// time zone of the new slots
+	loc := slots[0].Start.Location()
+	all := make([]models.Slot, 0, len(sessions)+len(slots))
+	for _, s := range sessions {
+		all = append(all, models.Slot{Start: s.Start.In(loc), End: s.End.In(loc)})
+	}
+	all = append(all, slots...)
+	checked := map[time.Time]bool{}
+	for _, slot := range slots {
+		week := startOfWeek(slot.Start)
+		if checked[week] {
+			continue
+		}
+		checked[week] = true
+
+		var weekly time.Duration
+		weeklyPrime := 0
+		for _, sl := range all {
+			if sl.Start.Before(week) || !sl.Start.Before(week.AddDate(0, 0, 7)) {
+				continue
+			}
+			weekly += sl.End.Sub(sl.Start)
+			if prime.overlaps(sl) {
+				weeklyPrime++
+			}
+		}
+		if q.MaxWeekly > 0 && weekly > q.MaxWeekly {
+			msgs = append(msgs, fmt.Sprintf("You would play %s in the week of %s (maximum is %s per week)",
+				forms.HumanDuration(weekly), humanDay(week, week.Location()), forms.HumanDuration(q.MaxWeekly)))
+		}
+		if q.MaxWeeklyPrime > 0 && weeklyPrime > q.MaxWeeklyPrime {
+			msgs = append(msgs, fmt.Sprintf("You would have %d prime-time sessions in the week of %s (maximum is %d per week)",
+				weeklyPrime, humanDay(week, week.Location()), q.MaxWeeklyPrime))
+		}
+	}
+	return msgs
+}
+
+// bookingCheck returns the check of the rules of the membership tier of the
+// user for booking the slots: the booking horizon, the allowed hours and the
+// quota. Users whose booking rights are suspended cannot book at all. The
+// session with the id excludeID, which is being rescheduled to the slots, does
+// not count towards the quota (use 0 for new bookings). The reasons of a
+// refusal are added to the form, unless it is nil. Admins are exempted from
+// the rules of their tier, their check is nil
+func (app *application) bookingCheck(user *models.User, form *forms.Form, slots []models.Slot, excludeID int) (*models.BookingCheck, error) {
+	if user.IsAdmin() {
+		return nil, nil
+	}
+	suspended, err := app.suspensions(user.ID)
+	if err != nil {
+		return nil, err
+	}
+	tier, err := app.tiers.Get(user.Tier)
+	if err != nil {
+		return nil, err
+	}
+	loc := app.location
+	if form != nil {
+		loc = form.Location
+	}
+	now := time.Now()
+	// the bookings of the whole week of the first slot are needed to check
+	// the weekly limits
+	from := startOfWeek(slots[0].Start)
+	if now.Before(from) {
+		from = now
+	}
+	verify := func(booked []*models.Session) error {
+		msgs := []string{}
+		if until, ok := suspended[user.ID]; ok {
+			msgs = append(msgs, fmt.Sprintf("Your booking rights are suspended until %s because you did not show up to %d sessions", humanDate(until, loc), app.rules.penalty.limit))
+		}
+		// the slots of a series are usually refused for the same reason,
+		// which is only reported once
+		refused := map[string]bool{}
+		for _, slot := range slots {
+			if msg := tier.Refusal(slot, now); msg != "" && !refused[msg] {
+				refused[msg] = true
+				msgs = append(msgs, msg)
+			}
+		}
+		msgs = append(msgs, checkQuota(tier.Quota, app.rules.prime, booked, slots, excludeID, now)...)
+		if len(msgs) == 0 {
+			return nil
+		}
+		if form != nil {
+			for _, msg := range msgs {
+				form.Errors.Add("quota", msg)
+			}
+		}
+		return models.ErrBookingRefused
+	}
+	return &models.BookingCheck{UserID: user.ID, From: from, Verify: verify}, nil
+}
+
+// tierErrors adds a form error for every rule of the membership tier which
+// the authenticated user would break by booking the slots, see bookingCheck.
+// It reports if the form is still valid and returns the check, which has to
+// be passed on to the booking: the quota is only guaranteed if it is verified
+// again inside the booking transaction
+func (app *application) tierErrors(r *http.Request, form *forms.Form, slots []models.Slot, excludeID int) (*models.BookingCheck, bool, error) {
+	user := app.authenticatedUser(r)
+	check, err := app.bookingCheck(user, form, slots, excludeID)
+	if err != nil {
+		return nil, false, err
+	}
+	if check == nil {
+		return nil, form.Valid(), nil
+	}
+	booked, err := app.session.ForUser(user.ID, check.From)
+	if err != nil {
+		return nil, false, err
+	}
+	err = check.Verify(booked)
+	if err != nil && err != models.ErrBookingRefused {
+		return nil, false, err
+	}
+	return check, form.Valid(), nil
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/erodrigufer/GoTennis/pkg/models"
+)
+
+func TestCheckQuota(t *testing.T) {
+	// Monday of the week in which all sessions take place
+	week := time.Date(2022, 5, 16, 0, 0, 0, 0, time.UTC)
+	now := week.Add(-24 * time.Hour)
+	at := func(day, hour int, d time.Duration) models.Slot {
+		start := week.AddDate(0, 0, day).Add(time.Duration(hour) * time.Hour)
+		return models.Slot{Start: start, End: start.Add(d)}
+	}
+	session := func(id, seriesID int, slot models.Slot) *models.Session {
+		return &models.Session{ID: id, SeriesID: seriesID, Start: slot.Start, End: slot.End}
+	}
+	quota := models.Quota{MaxActive: 2, MaxWeekly: 3 * time.Hour, MaxWeeklyPrime: 1}
+	prime := primeTime{start: 17 * time.Hour, end: 21 * time.Hour}
+
+	tests := []struct {
+		name      string
+		booked    []*models.Session
+		slots     []models.Slot
+		excludeID int
+		expected  int
+	}{
+		{
+			name:     "Within quota",
+			booked:   []*models.Session{session(1, 0, at(0, 10, time.Hour))},
+			slots:    []models.Slot{at(1, 10, time.Hour)},
+			expected: 0,
+		},
+		{
+			name: "Too many active bookings",
+			booked: []*models.Session{
+				session(1, 0, at(0, 10, time.Hour)),
+				session(2, 0, at(7, 10, time.Hour)),
+			},
+			slots:    []models.Slot{at(8, 10, time.Hour)},
+			expected: 1,
+		},
+		{
+			name: "Series count as one booking",
+			booked: []*models.Session{
+				session(1, 5, at(0, 10, time.Hour)),
+				session(2, 5, at(7, 10, time.Hour)),
+			},
+			slots:    []models.Slot{at(14, 10, time.Hour)},
+			expected: 0,
+		},
+		{
+			name:     "Too many hours per week",
+			booked:   []*models.Session{session(1, 0, at(0, 10, 2*time.Hour))},
+			slots:    []models.Slot{at(1, 10, 2*time.Hour)},
+			expected: 1,
+		},
+		{
+			name:     "Too many prime-time sessions",
+			booked:   []*models.Session{session(1, 0, at(0, 18, time.Hour))},
+			slots:    []models.Slot{at(1, 20, time.Hour)},
+			expected: 1,
+		},
+		{
+			name:      "Excluded session",
+			booked:    []*models.Session{session(1, 0, at(0, 18, 2*time.Hour))},
+			slots:     []models.Slot{at(0, 18, 2*time.Hour)},
+			excludeID: 1,
+			expected:  0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msgs := checkQuota(quota, prime, tt.booked, tt.slots, tt.excludeID, now)
+			if len(msgs) != tt.expected {
+				t.Errorf("expected %d messages; got %q", tt.expected, msgs)
+			}
+		})
+	}
+}
+
+func TestPrimeTimeOverlaps(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	prime := primeTime{start: 17 * time.Hour, end: 21 * time.Hour}
+	// daylight saving time starts in Berlin on 29 March 2026, the day has 23
+	// hours
+	at := func(day, hour int) models.Slot {
+		start := time.Date(2026, 3, day, hour, 0, 0, 0, berlin)
+		return models.Slot{Start: start, End: start.Add(time.Hour)}
+	}
+
+	tests := []struct {
+		name     string
+		slot     models.Slot
+		expected bool
+	}{
+		{name: "Before", slot: at(28, 16), expected: false},
+		{name: "Start", slot: at(28, 17), expected: true},
+		{name: "End", slot: at(28, 20), expected: true},
+		{name: "After", slot: at(28, 21), expected: false},
+		{name: "DSTBefore", slot: at(29, 16), expected: false},
+		{name: "DSTStart", slot: at(29, 17), expected: true},
+		{name: "DSTEnd", slot: at(29, 20), expected: true},
+		{name: "DSTAfter", slot: at(29, 21), expected: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := prime.overlaps(tt.slot); got != tt.expected {
+				t.Errorf("expected %t; got %t", tt.expected, got)
+			}
+		})
+	}
+}
@@ -24,11 +24,59 @@ func (app *application) routes() http.Handler {
 	mux.Get("/", app.sessionManager.Enable(noSurf(app.authenticate(http.HandlerFunc(app.root)))))
 	mux.Get("/session/create", app.sessionManager.Enable(noSurf(app.authenticate(app.requireAuthenticatedUser(http.HandlerFunc(app.createSessionForm))))))
 	mux.Post("/session/create", app.sessionManager.Enable(noSurf(app.authenticate(app.requireAuthenticatedUser(http.HandlerFunc(app.createSession))))))
+	mux.Get("/calendar", app.sessionManager.Enable(noSurf(app.authenticate(http.HandlerFunc(app.showCalendar)))))
+	mux.Get("/calendar/:court", app.sessionManager.Enable(noSurf(app.authenticate(http.HandlerFunc(app.showCalendar)))))
 	mux.Get("/session/:id", app.sessionManager.Enable(noSurf(app.authenticate(http.HandlerFunc(app.showSession)))))
+	mux.Get("/session/:id/checkin", app.sessionManager.Enable(noSurf(app.authenticate(app.requireAuthenticatedUser(http.HandlerFunc(app.checkIn))))))
+	mux.Post("/session/:id/noshow", app.sessionManager.Enable(noSurf(app.authenticate(app.requireAuthenticatedUser(http.HandlerFunc(app.flagNoShow))))))
+	mux.Post("/session/:id/noshow/clear", app.sessionManager.Enable(noSurf(app.authenticate(app.requireAuthenticatedUser(app.requireAdmin(http.HandlerFunc(app.clearNoShow)))))))
+	mux.Get("/session/:id/edit", app.sessionManager.Enable(noSurf(app.authenticate(app.requireAuthenticatedUser(http.HandlerFunc(app.editSessionForm))))))
+	mux.Post("/session/:id/edit", app.sessionManager.Enable(noSurf(app.authenticate(app.requireAuthenticatedUser(http.HandlerFunc(app.editSession))))))
+	mux.Post("/session/:id/cancel", app.sessionManager.Enable(noSurf(app.authenticate(app.requireAuthenticatedUser(http.HandlerFunc(app.cancelSession))))))
+	mux.Post("/session/:id/waitlist", app.sessionManager.Enable(noSurf(app.authenticate(app.requireAuthenticatedUser(http.HandlerFunc(app.joinWaitlist))))))
+	mux.Post("/session/:id/waitlist/leave", app.sessionManager.Enable(noSurf(app.authenticate(app.requireAuthenticatedUser(http.HandlerFunc(app.leaveWaitlist))))))
+	mux.Post("/session/:id/invite", app.sessionManager.Enable(noSurf(app.authenticate(app.requireAuthenticatedUser(http.HandlerFunc(app.inviteParticipant))))))
+	mux.Post("/session/:id/rsvp", app.sessionManager.Enable(noSurf(app.authenticate(app.requireAuthenticatedUser(http.HandlerFunc(app.respondInvitation))))))
+	mux.Post("/session/:id/open", app.sessionManager.Enable(noSurf(app.authenticate(app.requireAuthenticatedUser(http.HandlerFunc(app.openSession))))))
+	mux.Post("/session/:id/join", app.sessionManager.Enable(noSurf(app.authenticate(app.requireAuthenticatedUser(http.HandlerFunc(app.joinSession))))))
+	mux.Post("/session/:id/result", app.sessionManager.Enable(noSurf(app.authenticate(app.requireAuthenticatedUser(http.HandlerFunc(app.recordResult))))))
+	mux.Post("/session/:id/result/respond", app.sessionManager.Enable(noSurf(app.authenticate(app.requireAuthenticatedUser(http.HandlerFunc(app.respondResult))))))
+	mux.Get("/lesson/create", app.sessionManager.Enable(noSurf(app.authenticate(app.requireAuthenticatedUser(http.HandlerFunc(app.lessonForm))))))
+	mux.Post("/lesson/create", app.sessionManager.Enable(noSurf(app.authenticate(app.requireAuthenticatedUser(http.HandlerFunc(app.bookLesson))))))
+	mux.Get("/coach", app.sessionManager.Enable(noSurf(app.authenticate(app.requireAuthenticatedUser(app.requireCoach(http.HandlerFunc(app.coachDashboard)))))))
+	mux.Post("/coach/availability", app.sessionManager.Enable(noSurf(app.authenticate(app.requireAuthenticatedUser(app.requireCoach(http.HandlerFunc(app.addCoachWindow)))))))
+	mux.Post("/coach/availability/:id/delete", app.sessionManager.Enable(noSurf(app.authenticate(app.requireAuthenticatedUser(app.requireCoach(http.HandlerFunc(app.deleteCoachWindow)))))))
+	mux.Get("/partners", app.sessionManager.Enable(noSurf(app.authenticate(app.requireAuthenticatedUser(http.HandlerFunc(app.findPartners))))))
+	mux.Get("/ladder", app.sessionManager.Enable(noSurf(app.authenticate(http.HandlerFunc(app.showLadder)))))
+	mux.Post("/ladder/join", app.sessionManager.Enable(noSurf(app.authenticate(app.requireAuthenticatedUser(http.HandlerFunc(app.joinLadder))))))
+	mux.Get("/ladder/:user", app.sessionManager.Enable(noSurf(app.authenticate(http.HandlerFunc(app.showLadderPlayer)))))
+	mux.Post("/ladder/:user/challenge", app.sessionManager.Enable(noSurf(app.authenticate(app.requireAuthenticatedUser(http.HandlerFunc(app.challengePlayer))))))
+	mux.Get("/tournaments", app.sessionManager.Enable(noSurf(app.authenticate(http.HandlerFunc(app.showTournaments)))))
+	mux.Post("/tournament/create", app.sessionManager.Enable(noSurf(app.authenticate(app.requireAuthenticatedUser(app.requireAdmin(http.HandlerFunc(app.createTournament)))))))
+	mux.Get("/tournament/:id", app.sessionManager.Enable(noSurf(app.authenticate(http.HandlerFunc(app.showTournament)))))
+	mux.Post("/tournament/:id/register", app.sessionManager.Enable(noSurf(app.authenticate(app.requireAuthenticatedUser(http.HandlerFunc(app.registerTournament))))))
+	mux.Post("/tournament/:id/draw", app.sessionManager.Enable(noSurf(app.authenticate(app.requireAuthenticatedUser(app.requireAdmin(http.HandlerFunc(app.drawTournament)))))))
+	mux.Post("/tournament/:id/schedule", app.sessionManager.Enable(noSurf(app.authenticate(app.requireAuthenticatedUser(app.requireAdmin(http.HandlerFunc(app.scheduleTournament)))))))
+	mux.Get("/admin/tiers", app.sessionManager.Enable(noSurf(app.authenticate(app.requireAuthenticatedUser(app.requireAdmin(http.HandlerFunc(app.showTiers)))))))
+	mux.Get("/admin/tier/:name", app.sessionManager.Enable(noSurf(app.authenticate(app.requireAuthenticatedUser(app.requireAdmin(http.HandlerFunc(app.tierForm)))))))
+	mux.Post("/admin/tier/:name", app.sessionManager.Enable(noSurf(app.authenticate(app.requireAuthenticatedUser(app.requireAdmin(http.HandlerFunc(app.updateTier)))))))
+	mux.Get("/admin/members", app.sessionManager.Enable(noSurf(app.authenticate(app.requireAuthenticatedUser(app.requireAdmin(http.HandlerFunc(app.showMembers)))))))
+	mux.Post("/admin/member/:id/role", app.sessionManager.Enable(noSurf(app.authenticate(app.requireAuthenticatedUser(app.requireAdmin(http.HandlerFunc(app.setMemberRole)))))))
+	mux.Post("/admin/member/:id/tier", app.sessionManager.Enable(noSurf(app.authenticate(app.requireAuthenticatedUser(app.requireAdmin(http.HandlerFunc(app.setMemberTier)))))))
+	mux.Get("/admin/courts", app.sessionManager.Enable(noSurf(app.authenticate(app.requireAuthenticatedUser(app.requireAdmin(http.HandlerFunc(app.showCourts)))))))
+	mux.Post("/admin/court/:id/grid", app.sessionManager.Enable(noSurf(app.authenticate(app.requireAuthenticatedUser(app.requireAdmin(http.HandlerFunc(app.setCourtGrid)))))))
+	mux.Get("/admin/rainout", app.sessionManager.Enable(noSurf(app.authenticate(app.requireAuthenticatedUser(app.requireAdmin(http.HandlerFunc(app.rainOutForm)))))))
+	mux.Post("/admin/rainout", app.sessionManager.Enable(noSurf(app.authenticate(app.requireAuthenticatedUser(app.requireAdmin(http.HandlerFunc(app.rainOut)))))))
+	mux.Get("/series/:id", app.sessionManager.Enable(noSurf(app.authenticate(http.HandlerFunc(app.showSeries)))))
+	mux.Post("/series/:id/cancel", app.sessionManager.Enable(noSurf(app.authenticate(app.requireAuthenticatedUser(http.HandlerFunc(app.cancelSeries))))))
+	mux.Post("/series/:id/cancel/:session", app.sessionManager.Enable(noSurf(app.authenticate(app.requireAuthenticatedUser(http.HandlerFunc(app.cancelSeriesSession))))))
 	mux.Get("/user/signup", app.sessionManager.Enable(noSurf(app.authenticate(http.HandlerFunc(app.signupUserForm)))))
 	mux.Post("/user/signup", app.sessionManager.Enable(noSurf(app.authenticate(http.HandlerFunc(app.signupUser)))))
 	mux.Get("/user/login", app.sessionManager.Enable(noSurf(app.authenticate(http.HandlerFunc(app.loginUserForm)))))
 	mux.Post("/user/login", app.sessionManager.Enable(noSurf(app.authenticate(http.HandlerFunc(app.loginUser)))))
+	mux.Get("/user/bookings", app.sessionManager.Enable(noSurf(app.authenticate(app.requireAuthenticatedUser(http.HandlerFunc(app.showBookings))))))
+	mux.Get("/user/profile", app.sessionManager.Enable(noSurf(app.authenticate(app.requireAuthenticatedUser(http.HandlerFunc(app.profileForm))))))
+	mux.Post("/user/profile", app.sessionManager.Enable(noSurf(app.authenticate(app.requireAuthenticatedUser(http.HandlerFunc(app.updateProfile))))))
 	mux.Post("/user/logout", app.sessionManager.Enable(noSurf(app.authenticate(app.requireAuthenticatedUser(http.HandlerFunc(app.logoutUser))))))
 
 	// Create a handler/fileServer for all files in the static directory
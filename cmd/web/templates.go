@@ -1,6 +1,7 @@
 package main
 
 import (
+	"fmt"
 	"html/template"
 	"path/filepath"
 	"time"
@@ -13,28 +14,184 @@ import (
 // any dynamic data that is passed to the HTML templates
 type templateData struct {
 	AuthenticatedUser *models.User
+	Calendar          *calendar
+	Cancellable       map[int]bool            // ids of the sessions the authenticated user can cancel
+	CanCancel         bool                    // the authenticated user can cancel the session
+	Challengeable     map[int]bool            // ids of the players the authenticated user can challenge
+	Changes           []*models.SessionChange // previous versions of the session, the latest first
+	CheckInQR         template.URL            // QR code of the check-in URL of the session, as a data URI
+	CanEdit           bool                    // the authenticated user can change the session
+	CanInvite         bool                    // the authenticated user can invite players to the session
+	CanFlag           bool                    // the authenticated user can flag the session as a no-show
+	CanClearNoShow    bool                    // the authenticated user can clear the no-show of the session
+	CanConfirm        bool                    // the authenticated user can confirm the pending result of the session
+	CanRecord         bool                    // the authenticated user can record the result of the session
+	Court             *models.Court
+	Courts            []*models.Court
 	CSRFToken         string
 	CurrentYear       int
+	Entries           []*models.TournamentEntry
+	Equipment         []*models.Equipment
 	Flash             string
+	Invitation        *models.Participant // pending invitation of the authenticated user
+	Notifications     []*models.Notification
+	Pagination        *pagination // links to the other pages of a list
+	Quote             int         // price of a booking to be confirmed, in cents
+	Quoted            bool        // the price of the booking is shown before it is confirmed
+	Participants      []*models.Participant
+	Rentals           []*models.Rental // equipment rented for the session
+	Result            *models.MatchResult
+	Rounds            [][]*models.TournamentMatch // matches of a tournament, by round
+	Series            *models.Series
 	Session           *models.Session
 	Sessions          []*models.Session // a slice of sessions, useful to store the latest sessions
+	Users             []*models.User
+	Windows           []*models.CoachWindow // availability of the coaches
+	Waitlist          []*models.WaitlistEntry
+	History           []*models.RatingChange
+	Rating            *models.Rating // standing of a player on the ladder
+	Standings         []*models.Rating
+	Suspensions       map[int]time.Time // end of the suspension of the suspended users, by user id
+	Tier              *models.Tier
+	TimeZone          string // time zone of the club
+	Tiers             []*models.Tier
+	Tournament        *models.Tournament
+	Tournaments       []*models.Tournament
 	Form              *forms.Form
 }
 
-// Return a human readable representation of a time.Time object (at UTC)
-func humanDate(t time.Time) string {
+// Return a human readable representation of a time.Time object in the time
+// zone loc
+func humanDate(t time.Time, loc *time.Location) string {
 	if t.IsZero() {
 		return ""
 	}
-	// Convert time to UTC before formatting it
-	return t.UTC().Format("02 Jan 2006 at 15:04")
+	// Convert time to the time zone before formatting it
+	return t.In(loc).Format("02 Jan 2006 at 15:04")
+}
+
+// Return the time of the day of a time.Time object in the time zone loc,
+// useful to display the end of a session which starts on the same day
+func humanTime(t time.Time, loc *time.Location) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.In(loc).Format("15:04")
+}
+
+// Return the weekday and date of a time.Time object in the time zone loc,
+// used as the heading of the days in the calendar
+func humanDay(t time.Time, loc *time.Location) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.In(loc).Format("Mon 02 Jan 2006")
+}
+
+// Return the name of the weekday of a time.Time object in the time zone loc
+func humanWeekday(t time.Time, loc *time.Location) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.In(loc).Weekday().String()
+}
+
+// Return how long before or after now a time.Time object is, like 'in 3
+// hours' or '2 days ago'. The largest whole unit is used
+func humanRelative(t, now time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	d := t.Sub(now)
+	future := d > 0
+	if !future {
+		d = -d
+	}
+	var n int
+	var unit string
+	switch {
+	case d < time.Minute:
+		return "now"
+	case d < time.Hour:
+		n, unit = int(d/time.Minute), "minute"
+	case d < 24*time.Hour:
+		n, unit = int(d/time.Hour), "hour"
+	default:
+		n, unit = int(d/(24*time.Hour)), "day"
+	}
+	if n != 1 {
+		unit += "s"
+	}
+	if future {
+		return fmt.Sprintf("in %d %s", n, unit)
+	}
+	return fmt.Sprintf("%d %s ago", n, unit)
+}
+
+// Return a time of the day given as offset from midnight, like '08:30'
+func humanTimeOfDay(d time.Duration) string {
+	return time.Time{}.Add(d).Format(forms.TimeOfDayLayout)
+}
+
+// Return a human readable representation of a price in cents, like '€12.50',
+// a price of 0 is shown as 'free'
+func humanPrice(cents int) string {
+	if cents == 0 {
+		return "free"
+	}
+	return fmt.Sprintf("€%d.%02d", cents/100, cents%100)
+}
+
+// skillLevels returns all NTRP ratings (like '3.5') which a user can choose
+func skillLevels() []string {
+	levels := []string{}
+	for l := models.MinSkill; l <= models.MaxSkill; l += 0.5 {
+		levels = append(levels, fmt.Sprintf("%.1f", l))
+	}
+	return levels
+}
+
+// contains reports if the value is one of the values, e.g. one of the checked
+// boxes of a form field
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
 }
 
 // Initialize a template.FuncMap object in a global variable.
 // This is a string-keyed map which acts as a lookup between the names of of
-// custom template functions and the functions themselves
+// custom template functions and the functions themselves. The functions
+// which render times depend on the user, see timeFunctions
 var functions = template.FuncMap{
-	"humanDate": humanDate,
+	"contains": contains,
+	// dates without a time, like the start of a tournament, are stored at
+	// UTC and shown as they are in every time zone
+	"humanCalendarDay": func(t time.Time) string { return humanDay(t, time.UTC) },
+	"humanDuration":    forms.HumanDuration,
+	"humanPrice":       humanPrice,
+	"humanTimeOfDay":   humanTimeOfDay,
+	"playTimes":        func() []string { return models.PlayTimes },
+	"sessionDurations": func() []time.Duration { return models.SessionDurations },
+	"slotLengths":      func() []time.Duration { return models.SlotLengths },
+	"skillLevels":      skillLevels,
+}
+
+// timeFunctions returns the template functions which render times in the
+// time zone loc, relative times are relative to the time of the rendering.
+// They are registered at UTC to parse the templates and replaced in the
+// template sets of every time zone, see app.localTemplate
+func timeFunctions(loc *time.Location) template.FuncMap {
+	return template.FuncMap{
+		"humanDate":     func(t time.Time) string { return humanDate(t, loc) },
+		"humanDay":      func(t time.Time) string { return humanDay(t, loc) },
+		"humanRelative": func(t time.Time) string { return humanRelative(t, time.Now()) },
+		"humanTime":     func(t time.Time) string { return humanTime(t, loc) },
+		"humanWeekday":  func(t time.Time) string { return humanWeekday(t, loc) },
+	}
 }
 
 func newTemplateCache(dir string) (map[string]*template.Template, error) {
@@ -58,7 +215,7 @@ func newTemplateCache(dir string) (map[string]*template.Template, error) {
 		// First create an empty template set with template.New(), then register
 		// the custom template functions and finally parse the files
 		// Finally parse the page template file in to a template set
-		ts, err := template.New(name).Funcs(functions).ParseFiles(page)
+		ts, err := template.New(name).Funcs(functions).Funcs(timeFunctions(time.UTC)).ParseFiles(page)
 		if err != nil {
 			return nil, err
 		}
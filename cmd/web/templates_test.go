@@ -1,6 +1,8 @@
 package main
 
 import (
+	"bytes"
+	"html/template"
 	"testing"
 	"time"
 )
@@ -11,23 +13,33 @@ func TestHumanDate(t *testing.T) {
 	tests := []struct {
 		name     string
 		tm       time.Time
+		loc      *time.Location
 		expected string
 	}{
 		{
 			name:     "UTC",
 			tm:       time.Date(2020, 12, 17, 10, 0, 0, 0, time.UTC),
+			loc:      time.UTC,
 			expected: "17 Dec 2020 at 10:00",
 		},
 		{
 			name:     "Empty",
 			tm:       time.Time{},
+			loc:      time.UTC,
 			expected: "",
 		},
 		{
 			name:     "CET",
 			tm:       time.Date(2020, 12, 17, 10, 0, 0, 0, time.FixedZone("CET", 1*60*60)),
+			loc:      time.UTC,
 			expected: "17 Dec 2020 at 09:00",
 		},
+		{
+			name:     "Club time zone",
+			tm:       time.Date(2020, 12, 17, 23, 30, 0, 0, time.UTC),
+			loc:      time.FixedZone("CET", 1*60*60),
+			expected: "18 Dec 2020 at 00:30",
+		},
 	}
 	// Loop over the test cases
 	for _, tt := range tests {
@@ -36,10 +48,141 @@ func TestHumanDate(t *testing.T) {
 		// identify the sub-test in any log output) and the second parameter is
 		// and anonymous function containing the actual test for each case
 		t.Run(tt.name, func(t *testing.T) {
-			hd := humanDate(tt.tm)
+			hd := humanDate(tt.tm, tt.loc)
 			if hd != tt.expected {
 				t.Errorf("expected %q; got %q", tt.expected, hd)
 			}
 		})
 	}
 }
+
+func TestHumanTime(t *testing.T) {
+	tests := []struct {
+		name     string
+		tm       time.Time
+		loc      *time.Location
+		expected string
+	}{
+		{
+			name:     "UTC",
+			tm:       time.Date(2020, 12, 17, 10, 30, 0, 0, time.UTC),
+			loc:      time.UTC,
+			expected: "10:30",
+		},
+		{
+			name:     "Empty",
+			tm:       time.Time{},
+			loc:      time.UTC,
+			expected: "",
+		},
+		{
+			name:     "CET",
+			tm:       time.Date(2020, 12, 17, 10, 30, 0, 0, time.FixedZone("CET", 1*60*60)),
+			loc:      time.UTC,
+			expected: "09:30",
+		},
+		{
+			name:     "Club time zone",
+			tm:       time.Date(2020, 12, 17, 10, 30, 0, 0, time.UTC),
+			loc:      time.FixedZone("CET", 1*60*60),
+			expected: "11:30",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ht := humanTime(tt.tm, tt.loc)
+			if ht != tt.expected {
+				t.Errorf("expected %q; got %q", tt.expected, ht)
+			}
+		})
+	}
+}
+
+func TestHumanRelative(t *testing.T) {
+	now := time.Date(2020, 12, 17, 10, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		tm       time.Time
+		expected string
+	}{
+		{name: "Empty", tm: time.Time{}, expected: ""},
+		{name: "Now", tm: now.Add(30 * time.Second), expected: "now"},
+		{name: "Minutes", tm: now.Add(45 * time.Minute), expected: "in 45 minutes"},
+		{name: "One hour", tm: now.Add(time.Hour + 20*time.Minute), expected: "in 1 hour"},
+		{name: "Hours", tm: now.Add(3 * time.Hour), expected: "in 3 hours"},
+		{name: "Days ago", tm: now.Add(-50 * time.Hour), expected: "2 days ago"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hr := humanRelative(tt.tm, now)
+			if hr != tt.expected {
+				t.Errorf("expected %q; got %q", tt.expected, hr)
+			}
+		})
+	}
+}
+
+func TestHumanPrice(t *testing.T) {
+	tests := []struct {
+		name     string
+		cents    int
+		expected string
+	}{
+		{name: "Free", cents: 0, expected: "free"},
+		{name: "Euros", cents: 1200, expected: "€12.00"},
+		{name: "Cents", cents: 1805, expected: "€18.05"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hp := humanPrice(tt.cents)
+			if hp != tt.expected {
+				t.Errorf("expected %q; got %q", tt.expected, hp)
+			}
+		})
+	}
+}
+
+func TestLocalTemplate(t *testing.T) {
+	app := newTestApplication(t)
+	ts, err := template.New("page").Funcs(timeFunctions(time.UTC)).Parse(`{{humanTime .}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tm := time.Date(2022, 5, 17, 10, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		loc      *time.Location
+		expected string
+	}{
+		{name: "UTC", loc: time.UTC, expected: humanTime(tm, time.UTC)},
+		{name: "CEST", loc: berlin, expected: humanTime(tm, berlin)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			local, err := app.localTemplate("page", ts, tt.loc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			buf := new(bytes.Buffer)
+			if err = local.Execute(buf, tm); err != nil {
+				t.Fatal(err)
+			}
+			if buf.String() != tt.expected {
+				t.Errorf("expected %q; got %q", tt.expected, buf.String())
+			}
+			// the template set of a time zone is only cloned once
+			again, err := app.localTemplate("page", ts, tt.loc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if again != local {
+				t.Errorf("expected the cached template set")
+			}
+		})
+	}
+}
@@ -7,14 +7,23 @@ import (
 	"net/http/cookiejar"
 	"net/http/httptest"
 	"testing"
+	"time"
+
+	"github.com/erodrigufer/GoTennis/pkg/models/mock"
+	"github.com/golangcollege/sessions"
 )
 
 // newTestApplication helper returns an instance of our application struct
 // containing mocked dependencies.
 func newTestApplication(t *testing.T) *application {
+	sessionManager := sessions.New([]byte("3dSm5MnygFHh7XidAtbskXrjbwfoJcbJ"))
+	sessionManager.Lifetime = 12 * time.Hour
+	sessionManager.Secure = true
 	return &application{
-		errorLog: log.New(ioutil.Discard, "", 0),
-		infoLog:  log.New(ioutil.Discard, "", 0),
+		courts:         &mock.CourtModel{},
+		errorLog:       log.New(ioutil.Discard, "", 0),
+		infoLog:        log.New(ioutil.Discard, "", 0),
+		sessionManager: sessionManager,
 	}
 }
 
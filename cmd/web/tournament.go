@@ -0,0 +1,230 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/erodrigufer/GoTennis/pkg/models"
+	"github.com/erodrigufer/GoTennis/pkg/tennis"
+)
+
+// The matches of a round are scheduled in the week after the day of the
+// round, starting at the full and half hours
+const (
+	tournamentSearchDays = 7
+	tournamentSlotStep   = 30 * time.Minute
+)
+
+// seedPlayers returns the ids of the players registered for a tournament in
+// the order of their seeds: the players on the ladder ordered by their rung,
+// followed by the other players in the order in which they registered
+func seedPlayers(entries []*models.TournamentEntry, standings []*models.Rating) []int {
+	registered := map[int]bool{}
+	for _, e := range entries {
+		registered[e.UserID] = true
+	}
+	seeded := make([]int, 0, len(entries))
+	onLadder := map[int]bool{}
+	for _, r := range standings {
+		if registered[r.UserID] {
+			seeded = append(seeded, r.UserID)
+			onLadder[r.UserID] = true
+		}
+	}
+	for _, e := range entries {
+		if !onLadder[e.UserID] {
+			seeded = append(seeded, e.UserID)
+		}
+	}
+	return seeded
+}
+
+// buildDraw returns all the matches of the draw of a tournament for the
+// players sorted by seed. In a knockout draw the players with a bye already
+// advance to the second round
+func buildDraw(format string, players []int) []*models.TournamentMatch {
+	id := func(i int) int {
+		if i == tennis.Bye {
+			return 0
+		}
+		return players[i]
+	}
+	matches := []*models.TournamentMatch{}
+	if format == models.FormatRoundRobin {
+		for r, round := range tennis.RoundRobin(len(players)) {
+			position := 0
+			for _, p := range round {
+				if p.Home == tennis.Bye || p.Away == tennis.Bye {
+					continue
+				}
+				matches = append(matches, &models.TournamentMatch{
+					Round: r + 1, Position: position, HomeID: id(p.Home), AwayID: id(p.Away),
+				})
+				position++
+			}
+		}
+		return matches
+	}
+
+	pairings, rounds := tennis.SingleElimination(len(players))
+	// the matches of the later rounds are created empty, byRound[r][p] is the
+	// match at the position p of the round r+1
+	byRound := make([][]*models.TournamentMatch, rounds)
+	for r := range byRound {
+		for p := 0; p < len(pairings)>>r; p++ {
+			m := &models.TournamentMatch{Round: r + 1, Position: p}
+			byRound[r] = append(byRound[r], m)
+			matches = append(matches, m)
+		}
+	}
+	for p, pairing := range pairings {
+		m := byRound[0][p]
+		m.HomeID, m.AwayID = id(pairing.Home), id(pairing.Away)
+		if m.HomeID == 0 {
+			m.HomeID, m.AwayID = m.AwayID, 0
+		}
+		// a player without an opponent advances to the next round
+		if m.AwayID == 0 {
+			m.WinnerID = m.HomeID
+			next := byRound[1][p/2]
+			if p%2 == 0 {
+				next.HomeID = m.WinnerID
+			} else {
+				next.AwayID = m.WinnerID
+			}
+		}
+	}
+	return matches
+}
+
+// scheduleMatch books a singles session for a tournament match whose players
+// are known, on the first free court whose booking grid fits the match in the
+// week of its round. The home player books the session and the away player
+// takes part in it, the booking quotas do not apply. It reports if a free
+// slot was found
+func (app *application) scheduleMatch(t *models.Tournament, m *models.TournamentMatch) (bool, error) {
+	courts, err := app.courts.All()
+	if err != nil {
+		return false, err
+	}
+	now := time.Now()
+	title := fmt.Sprintf("%s, round %d", t.Name, m.Round)
+	content := fmt.Sprintf("%s against %s", m.HomeName, m.AwayName)
+	// the start of the tournament is a date, the matches are played on the
+	// days in the time zone of the club
+	first := time.Date(t.Start.Year(), t.Start.Month(), t.Start.Day()+7*(m.Round-1), 0, 0, 0, 0, app.location)
+	// a round which is late starts today
+	year, month, day := now.In(app.location).Date()
+	if today := time.Date(year, month, day, 0, 0, 0, 0, app.location); first.Before(today) {
+		first = today
+	}
+	for d := 0; d < tournamentSearchDays; d++ {
+		date := first.AddDate(0, 0, d)
+		availability, err := app.schedule.Availability(date, date.AddDate(0, 0, 1))
+		if err != nil {
+			return false, err
+		}
+		last := time.Date(date.Year(), date.Month(), date.Day(), calendarLastHour, 0, 0, 0, app.location)
+		for start := time.Date(date.Year(), date.Month(), date.Day(), calendarFirstHour, 0, 0, 0, app.location); !start.Add(t.MatchDuration).After(last); start = start.Add(tournamentSlotStep) {
+			if start.Before(now) {
+				continue
+			}
+			slot := models.Slot{Start: start, End: start.Add(t.MatchDuration)}
+			for _, c := range courts {
+				// the match has to fit the booking grid of the court
+				if availability.Closed(c.ID, slot) != "" || !c.OnGrid(slot.Start) || !c.AllowedDuration(t.MatchDuration) {
+					continue
+				}
+				// tournament matches are free, the session has no price
+				id, err := app.tournaments.BookMatch(m.ID, &models.Session{
+					UserID:  m.HomeID,
+					CourtID: c.ID,
+					Kind:    models.KindSingles,
+					Title:   title,
+					Content: content,
+					Start:   slot.Start,
+					End:     slot.End,
+				}, m.AwayID)
+				if err == models.ErrSlotTaken || err == models.ErrCourtClosed || err == models.ErrOffGrid {
+					continue
+				} else if err == models.ErrNoRecord {
+					// the match was scheduled by another request in the
+					// meantime
+					return true, nil
+				} else if err != nil {
+					return false, err
+				}
+				// the match is already booked, a failed notification is
+				// only logged
+				msg := fmt.Sprintf("Your match of %s was scheduled on %s on %s", t.Name, c.Name, humanDate(slot.Start, app.location))
+				for _, userID := range []int{m.HomeID, m.AwayID} {
+					if _, err = app.notifications.Insert(userID, msg, fmt.Sprintf("/session/%d", id)); err != nil {
+						app.errorLog.Print(err)
+					}
+				}
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// scheduleReady schedules all the matches of a tournament whose players are
+// known and which are not scheduled yet. It returns the number of matches for
+// which no free slot was found
+func (app *application) scheduleReady(t *models.Tournament) (int, error) {
+	matches, err := app.tournaments.Matches(t.ID)
+	if err != nil {
+		return 0, err
+	}
+	missed := 0
+	for _, m := range matches {
+		if !m.Ready() || m.SessionID != 0 {
+			continue
+		}
+		ok, err := app.scheduleMatch(t, m)
+		if err != nil {
+			return 0, err
+		}
+		if !ok {
+			missed++
+		}
+	}
+	return missed, nil
+}
+
+// advanceTournament schedules the matches of the next round of the
+// tournament whose match was played in the session, if the session is one.
+// The winner was already recorded together with the result. Errors are only
+// logged: the result is already stored, and an admin can retry to schedule
+// the matches of the tournament
+func (app *application) advanceTournament(s *models.Session) {
+	m, err := app.tournaments.MatchForSession(s.ID)
+	if err == models.ErrNoRecord {
+		return
+	} else if err != nil {
+		app.errorLog.Print(err)
+		return
+	}
+	t, err := app.tournaments.Get(m.TournamentID)
+	if err != nil {
+		app.errorLog.Print(err)
+		return
+	}
+	if _, err = app.scheduleReady(t); err != nil {
+		app.errorLog.Print(err)
+	}
+}
+
+// confirmResult confirms the pending result of the session on behalf of the
+// user with the id userID. The ratings of the ladder and the tournament draw
+// are updated together with the result, then the next matches of the
+// tournament are scheduled
+func (app *application) confirmResult(s *models.Session, participants []*models.Participant, userID int) error {
+	err := app.results.Confirm(s.ID, userID, singlesOpponent(s, participants), app.ladderRules.k)
+	if err != nil {
+		return err
+	}
+	app.advanceTournament(s)
+	return nil
+}
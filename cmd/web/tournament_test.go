@@ -0,0 +1,55 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/erodrigufer/GoTennis/pkg/models"
+)
+
+func TestSeedPlayers(t *testing.T) {
+	entries := []*models.TournamentEntry{{UserID: 1}, {UserID: 2}, {UserID: 3}, {UserID: 4}}
+	// players 3 and 1 are on the ladder, player 5 did not register
+	standings := []*models.Rating{{UserID: 5, Rank: 1}, {UserID: 3, Rank: 2}, {UserID: 1, Rank: 3}}
+	seeded := seedPlayers(entries, standings)
+	expected := []int{3, 1, 2, 4}
+	if len(seeded) != len(expected) {
+		t.Fatalf("expected %v; got %v", expected, seeded)
+	}
+	for i := range expected {
+		if seeded[i] != expected[i] {
+			t.Errorf("expected %v; got %v", expected, seeded)
+			break
+		}
+	}
+}
+
+func TestBuildDraw(t *testing.T) {
+	t.Run("SingleElimination", func(t *testing.T) {
+		// 3 players in a draw of 4, the top seed has a bye
+		matches := buildDraw(models.FormatSingleElimination, []int{10, 20, 30})
+		if len(matches) != 3 {
+			t.Fatalf("expected 3 matches; got %d", len(matches))
+		}
+		bye, first, final := matches[0], matches[1], matches[2]
+		if !bye.Bye() || bye.WinnerID != 10 {
+			t.Errorf("expected a bye for the top seed; got %+v", bye)
+		}
+		if first.HomeID != 20 || first.AwayID != 30 || !first.Ready() {
+			t.Errorf("expected seed 2 against seed 3; got %+v", first)
+		}
+		if final.Round != 2 || final.HomeID != 10 || final.AwayID != 0 {
+			t.Errorf("expected the top seed in the final; got %+v", final)
+		}
+	})
+	t.Run("RoundRobin", func(t *testing.T) {
+		matches := buildDraw(models.FormatRoundRobin, []int{10, 20, 30})
+		if len(matches) != 3 {
+			t.Fatalf("expected 3 matches; got %d", len(matches))
+		}
+		for _, m := range matches {
+			if !m.Ready() {
+				t.Errorf("expected both players to be known; got %+v", m)
+			}
+		}
+	})
+}
@@ -6,10 +6,9 @@ require (
 	github.com/bmizerany/pat v0.0.0-20210406213842-e4b6760bdd6f
 	github.com/go-sql-driver/mysql v1.6.0
 	github.com/golangcollege/sessions v1.2.0
+	github.com/justinas/nosurf v1.1.1
+	github.com/skip2/go-qrcode v0.0.0-20200617195104-da1b6568686e
 	golang.org/x/crypto v0.0.0-20200317142112-1b76d66859c6
 )
 
-require (
-	github.com/justinas/nosurf v1.1.1 // indirect
-	golang.org/x/sys v0.0.0-20190412213103-97732733099d // indirect
-)
+require golang.org/x/sys v0.0.0-20190412213103-97732733099d // indirect
@@ -4,10 +4,22 @@ import (
 	"fmt"
 	"net/url"
 	"regexp"
+	"strconv"
 	"strings"
+	"time"
 	"unicode/utf8"
 )
 
+// Layout of the date and time values sent by an HTML 'datetime-local' input
+// field
+const DateTimeLayout = "2006-01-02T15:04"
+
+// Layout of the date values sent by an HTML 'date' input field
+const DateLayout = "2006-01-02"
+
+// Layout of the time values sent by an HTML 'time' input field
+const TimeOfDayLayout = "15:04"
+
 // parse a regex pattern and compile the regexp to sanity check the format of an
 // email address. This returns a *regexp.Regexp object, or panics in the event
 // of an error. This is done once at runtime, and stores the compiled regular
@@ -21,8 +33,9 @@ var EmailRX = regexp.MustCompile("^[a-zA-Z0-9.!#$%&'*+\\/=?^_`{|}~-]+@[a-zA-Z0-9
 // (to hold the form data) and an Errors field to hold any validation errors
 // for the form data.
 type Form struct {
-	url.Values        // values parsed from the POST request body
-	Errors     errors // type described in errors.go
+	url.Values                // values parsed from the POST request body
+	Errors     errors         // type described in errors.go
+	Location   *time.Location // time zone of the dates and times of the form, UTC if nil
 }
 
 // Define a New() function to initialize a custom Form struct,
@@ -30,9 +43,18 @@ type Form struct {
 // form object
 func New(data url.Values) *Form {
 	return &Form{
-		data,
-		errors(map[string][]string{}), // initialize an empty errors map
+		Values: data,
+		Errors: errors(map[string][]string{}), // initialize an empty errors map
+	}
+}
+
+// location returns the time zone in which the dates and times of the form
+// are parsed
+func (f *Form) location() *time.Location {
+	if f.Location == nil {
+		return time.UTC
 	}
+	return f.Location
 }
 
 // Implement a Required method to check that specific fields in the form
@@ -76,6 +98,24 @@ func (f *Form) PermittedValues(field string, opts ...string) {
 	f.Errors.Add(field, "This field is invalid")
 }
 
+// PermittedMultiValues checks that every value of a field which can hold
+// multiple values (e.g. a group of checkboxes) is one of the permitted values
+func (f *Form) PermittedMultiValues(field string, opts ...string) {
+	for _, value := range f.Values[field] {
+		permitted := false
+		for _, opt := range opts {
+			if value == opt {
+				permitted = true
+				break
+			}
+		}
+		if !permitted {
+			f.Errors.Add(field, "This field is invalid")
+			return
+		}
+	}
+}
+
 // Implement a Valid method which returns true if there are no errors.
 func (f *Form) Valid() bool {
 	return len(f.Errors) == 0
@@ -106,3 +146,177 @@ func (f *Form) MatchesPattern(field string, pattern *regexp.Regexp) {
 		f.Errors.Add(field, "This field is invalid")
 	}
 }
+
+// GetTime parses the value of a specific field as a date and time in the
+// DateTimeLayout format, in the time zone of the form. If the field is blank
+// or cannot be parsed, the zero time is returned
+func (f *Form) GetTime(field string) time.Time {
+	t, err := time.ParseInLocation(DateTimeLayout, f.Get(field), f.location())
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
+
+// ValidDateTime checks that specific fields in the form contain a date and
+// time in the DateTimeLayout format. If any field fails this check, it adds
+// the appropriate message to the form errors.
+func (f *Form) ValidDateTime(fields ...string) {
+	for _, field := range fields {
+		value := f.Get(field)
+		if value == "" {
+			continue
+		}
+		if _, err := time.Parse(DateTimeLayout, value); err != nil {
+			f.Errors.Add(field, "This field must be a valid date and time")
+		}
+	}
+}
+
+// FutureDateTime checks that the date and time of a specific field in the
+// form lies in the future. Fields which cannot be parsed are ignored, they
+// should be checked with ValidDateTime.
+func (f *Form) FutureDateTime(field string) {
+	t := f.GetTime(field)
+	if t.IsZero() {
+		return
+	}
+	if !t.After(time.Now()) {
+		f.Errors.Add(field, "This field must be in the future")
+	}
+}
+
+// After checks that the date and time of a specific field in the form comes
+// after the date and time of the field 'before'. If the check fails, then it
+// adds the appropriate message to the form errors.
+func (f *Form) After(field, before string) {
+	t, b := f.GetTime(field), f.GetTime(before)
+	if t.IsZero() || b.IsZero() {
+		return
+	}
+	if !t.After(b) {
+		f.Errors.Add(field, fmt.Sprintf("This field must be after the %s", before))
+	}
+}
+
+// MaxDuration checks that the time between the fields 'start' and 'end' is
+// not longer than d. If the check fails, then it adds the appropriate message
+// to the form errors of the 'end' field.
+func (f *Form) MaxDuration(start, end string, d time.Duration) {
+	s, e := f.GetTime(start), f.GetTime(end)
+	if s.IsZero() || e.IsZero() {
+		return
+	}
+	if e.Sub(s) > d {
+		f.Errors.Add(end, fmt.Sprintf("This field is too late (maximum duration is %s)", HumanDuration(d)))
+	}
+}
+
+// HumanDuration returns a human readable representation of a duration, like
+// '1h30m' or '45m' instead of '1h30m0s' or '45m0s'
+func HumanDuration(d time.Duration) string {
+	h, m := int(d.Hours()), int(d.Minutes())%60
+	switch {
+	case h == 0:
+		return fmt.Sprintf("%dm", m)
+	case m == 0:
+		return fmt.Sprintf("%dh", h)
+	default:
+		return fmt.Sprintf("%dh%dm", h, m)
+	}
+}
+
+// GetDate parses the value of a specific field as a date in the DateLayout
+// format (at UTC, like the DATE columns of the db, whatever the time zone of
+// the form). If the field is blank or cannot be parsed, the zero time is
+// returned
+func (f *Form) GetDate(field string) time.Time {
+	t, err := time.Parse(DateLayout, f.Get(field))
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
+
+// ValidDate checks that specific fields in the form contain a date in the
+// DateLayout format. If any field fails this check, it adds the appropriate
+// message to the form errors.
+func (f *Form) ValidDate(fields ...string) {
+	for _, field := range fields {
+		value := f.Get(field)
+		if value == "" {
+			continue
+		}
+		if _, err := time.Parse(DateLayout, value); err != nil {
+			f.Errors.Add(field, "This field must be a valid date")
+		}
+	}
+}
+
+// GetTimeOfDay parses the value of a specific field as a time of the day in
+// the TimeOfDayLayout format and returns it as offset from midnight. If the
+// field is blank or cannot be parsed, 0 is returned
+func (f *Form) GetTimeOfDay(field string) time.Duration {
+	t, err := time.Parse(TimeOfDayLayout, f.Get(field))
+	if err != nil {
+		return 0
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute
+}
+
+// ValidTimeOfDay checks that specific fields in the form contain a time of
+// the day in the TimeOfDayLayout format. If any field fails this check, it
+// adds the appropriate message to the form errors.
+func (f *Form) ValidTimeOfDay(fields ...string) {
+	for _, field := range fields {
+		value := f.Get(field)
+		if value == "" {
+			continue
+		}
+		if _, err := time.Parse(TimeOfDayLayout, value); err != nil {
+			f.Errors.Add(field, "This field must be a valid time")
+		}
+	}
+}
+
+// ValidTimeZone checks that a specific field in the form contains the name
+// of a time zone of the IANA database, like 'Europe/Berlin'. If the field
+// is blank, the check is skipped
+func (f *Form) ValidTimeZone(field string) {
+	value := f.Get(field)
+	if value == "" {
+		return
+	}
+	// LoadLocation also accepts "Local", which depends on the server
+	if _, err := time.LoadLocation(value); err != nil || value == "Local" {
+		f.Errors.Add(field, "This field must be a valid time zone, like Europe/Berlin")
+	}
+}
+
+// GetInt returns the value of a specific field as an integer. If the field is
+// blank or is not an integer, 0 is returned
+func (f *Form) GetInt(field string) int {
+	n, err := strconv.Atoi(f.Get(field))
+	if err != nil {
+		return 0
+	}
+	return n
+}
+
+// IntRange checks that a specific field in the form contains an integer
+// between min and max (both included). If the check fails, then it adds the
+// appropriate message to the form errors.
+func (f *Form) IntRange(field string, min, max int) {
+	value := f.Get(field)
+	if value == "" {
+		return
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		f.Errors.Add(field, "This field must be a number")
+		return
+	}
+	if n < min || n > max {
+		f.Errors.Add(field, fmt.Sprintf("This field must be between %d and %d", min, max))
+	}
+}
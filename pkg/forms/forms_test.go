@@ -0,0 +1,180 @@
+package forms
+
+import (
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestTimeSlotValidation(t *testing.T) {
+	future := time.Now().Add(48 * time.Hour).UTC()
+	start := future.Format(DateTimeLayout)
+	// Slice of anonymous structs containing the test case name, the start and
+	// end values of the form and the fields which are expected to be invalid
+	tests := []struct {
+		name    string
+		start   string
+		end     string
+		invalid []string
+	}{
+		{
+			name:  "Valid",
+			start: start,
+			end:   future.Add(90 * time.Minute).Format(DateTimeLayout),
+		},
+		{
+			name:    "Unparsable",
+			start:   "tomorrow",
+			end:     "2020-13-01T10:00",
+			invalid: []string{"start", "end"},
+		},
+		{
+			name:    "Past",
+			start:   "2020-12-17T10:00",
+			end:     "2020-12-17T11:00",
+			invalid: []string{"start"},
+		},
+		{
+			name:    "EndBeforeStart",
+			start:   start,
+			end:     future.Add(-time.Hour).Format(DateTimeLayout),
+			invalid: []string{"end"},
+		},
+		{
+			name:    "TooLong",
+			start:   start,
+			end:     future.Add(3 * time.Hour).Format(DateTimeLayout),
+			invalid: []string{"end"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := New(url.Values{"start": {tt.start}, "end": {tt.end}})
+			f.ValidDateTime("start", "end")
+			f.FutureDateTime("start")
+			f.After("end", "start")
+			f.MaxDuration("start", "end", 2*time.Hour)
+			if len(f.Errors) != len(tt.invalid) {
+				t.Errorf("expected %d invalid fields; got %v", len(tt.invalid), f.Errors)
+			}
+			for _, field := range tt.invalid {
+				if f.Errors.Get(field) == "" {
+					t.Errorf("expected field %q to be invalid", field)
+				}
+			}
+		})
+	}
+}
+
+func TestPermittedMultiValues(t *testing.T) {
+	tests := []struct {
+		name   string
+		values []string
+		valid  bool
+	}{
+		{name: "Empty", values: nil, valid: true},
+		{name: "Permitted", values: []string{"a", "c"}, valid: true},
+		{name: "NotPermitted", values: []string{"a", "d"}, valid: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := New(url.Values{"field": tt.values})
+			f.PermittedMultiValues("field", "a", "b", "c")
+			if f.Valid() != tt.valid {
+				t.Errorf("expected valid to be %t; got %v", tt.valid, f.Errors)
+			}
+		})
+	}
+}
+
+func TestTimeOfDay(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    string
+		valid    bool
+		expected time.Duration
+	}{
+		{name: "Empty", value: "", valid: true, expected: 0},
+		{name: "Morning", value: "08:30", valid: true, expected: 8*time.Hour + 30*time.Minute},
+		{name: "Evening", value: "22:00", valid: true, expected: 22 * time.Hour},
+		{name: "Invalid", value: "25:00", valid: false, expected: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := New(url.Values{"time": {tt.value}})
+			f.ValidTimeOfDay("time")
+			if f.Valid() != tt.valid {
+				t.Errorf("expected valid to be %t; got %v", tt.valid, f.Errors)
+			}
+			if d := f.GetTimeOfDay("time"); d != tt.expected {
+				t.Errorf("expected %v; got %v", tt.expected, d)
+			}
+		})
+	}
+}
+
+func TestGetTimeLocation(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name     string
+		location *time.Location
+		expected time.Time
+	}{
+		{name: "UTC", location: nil, expected: time.Date(2022, 7, 1, 10, 0, 0, 0, time.UTC)},
+		{name: "Summer time", location: berlin, expected: time.Date(2022, 7, 1, 8, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := New(url.Values{"start": {"2022-07-01T10:00"}})
+			f.Location = tt.location
+			if got := f.GetTime("start"); !got.Equal(tt.expected) {
+				t.Errorf("expected %v; got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestValidTimeZone(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		valid bool
+	}{
+		{name: "Empty", value: "", valid: true},
+		{name: "Valid", value: "America/New_York", valid: true},
+		{name: "Unknown", value: "Mars/Olympus_Mons", valid: false},
+		{name: "Local", value: "Local", valid: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := New(url.Values{"timezone": {tt.value}})
+			f.ValidTimeZone("timezone")
+			if f.Valid() != tt.valid {
+				t.Errorf("expected valid to be %t; got %v", tt.valid, f.Errors)
+			}
+		})
+	}
+}
+
+func TestHumanDuration(t *testing.T) {
+	tests := []struct {
+		name     string
+		d        time.Duration
+		expected string
+	}{
+		{name: "Hours", d: 2 * time.Hour, expected: "2h"},
+		{name: "Minutes", d: 45 * time.Minute, expected: "45m"},
+		{name: "Both", d: 90 * time.Minute, expected: "1h30m"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hd := HumanDuration(tt.d)
+			if hd != tt.expected {
+				t.Errorf("expected %q; got %q", tt.expected, hd)
+			}
+		})
+	}
+}
@@ -0,0 +1,54 @@
+package mock
+
+import (
+	"time"
+
+	"github.com/erodrigufer/GoTennis/pkg/models"
+)
+
+var mockCourt = &models.Court{
+	ID:      1,
+	Name:    "Court 1",
+	Surface: "clay",
+	Created: time.Now(),
+}
+
+type CourtModel struct{}
+
+func (m *CourtModel) Insert(name, surface string) (int, error) {
+	return 2, nil
+}
+
+func (m *CourtModel) Get(id int) (*models.Court, error) {
+	switch id {
+	case 1:
+		return mockCourt, nil
+	default:
+		return nil, models.ErrNoRecord
+	}
+}
+
+func (m *CourtModel) All() ([]*models.Court, error) {
+	return []*models.Court{mockCourt}, nil
+}
+
+func (m *CourtModel) Update(id int, name, surface string) error {
+	if id != 1 {
+		return models.ErrNoRecord
+	}
+	return nil
+}
+
+func (m *CourtModel) SetGrid(id int, slotLength time.Duration, durations []time.Duration) error {
+	if id != 1 {
+		return models.ErrNoRecord
+	}
+	return nil
+}
+
+func (m *CourtModel) Delete(id int) error {
+	if id != 1 {
+		return models.ErrNoRecord
+	}
+	return nil
+}
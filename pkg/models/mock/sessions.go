@@ -7,18 +7,28 @@ import (
 )
 
 var mockSession = &models.Session{
-	ID:      1,
-	Title:   "An old silent pond",
-	Content: "An old silent pond...",
-	Created: time.Now(),
-	Expires: time.Now(),
+	ID:        1,
+	UserID:    1,
+	UserName:  "Alice",
+	CourtID:   1,
+	CourtName: "Court 1",
+	Kind:      models.KindSingles,
+	Title:     "An old silent pond",
+	Content:   "An old silent pond...",
+	Created:   time.Now(),
+	Start:     time.Now().Add(24 * time.Hour),
+	End:       time.Now().Add(25 * time.Hour),
+	Status:    models.StatusBooked,
 }
 
 type SessionModel struct{}
 
 // Insert a new session into the db, it returns the id of the newly inserted
 // row in the db
-func (m *SessionModel) Insert(title, content, expires string) (int, error) {
+func (m *SessionModel) Insert(s *models.Session, rentals []*models.Rental, check *models.BookingCheck) (int, error) {
+	if s.CourtID == mockSession.CourtID && s.Start.Before(mockSession.End) && s.End.After(mockSession.Start) {
+		return 0, models.ErrSlotTaken
+	}
 	return 2, nil
 }
 func (m *SessionModel) Get(id int) (*models.Session, error) {
@@ -29,6 +39,35 @@ func (m *SessionModel) Get(id int) (*models.Session, error) {
 		return nil, models.ErrNoRecord
 	}
 }
-func (m *SessionModel) Latest() ([]*models.Session, error) {
+func (m *SessionModel) Latest(courtID int) ([]*models.Session, error) {
 	return []*models.Session{mockSession}, nil
 }
+
+func (m *SessionModel) Between(courtID int, from, to time.Time) ([]*models.Session, error) {
+	if (courtID == 0 || courtID == mockSession.CourtID) && mockSession.Start.Before(to) && mockSession.End.After(from) {
+		return []*models.Session{mockSession}, nil
+	}
+	return []*models.Session{}, nil
+}
+
+func (m *SessionModel) Cancel(id, userID int) error {
+	if id != 1 {
+		return models.ErrNoRecord
+	}
+	return nil
+}
+
+func (m *SessionModel) ForUser(userID int, from time.Time) ([]*models.Session, error) {
+	if userID == mockSession.UserID && mockSession.End.After(from) {
+		return []*models.Session{mockSession}, nil
+	}
+	return []*models.Session{}, nil
+}
+
+func (m *SessionModel) SetOpen(id int, open bool) error {
+	return nil
+}
+
+func (m *SessionModel) OpenFor(u *models.User) ([]*models.Session, error) {
+	return []*models.Session{}, nil
+}
@@ -0,0 +1,64 @@
+package mock
+
+import (
+	"time"
+
+	"github.com/erodrigufer/GoTennis/pkg/models"
+)
+
+var mockUser = &models.User{
+	ID:      1,
+	Name:    "Alice",
+	Email:   "alice@example.com",
+	Role:    models.RoleMember,
+	Created: time.Now(),
+}
+
+type UserModel struct{}
+
+func (m *UserModel) Insert(name, email, password string) error {
+	switch email {
+	case "dupe@example.com":
+		return models.ErrDuplicateEmail
+	default:
+		return nil
+	}
+}
+
+func (m *UserModel) Authenticate(email, password string) (int, error) {
+	switch email {
+	case "alice@example.com":
+		return 1, nil
+	default:
+		return 0, models.ErrInvalidCredentials
+	}
+}
+
+func (m *UserModel) Get(id int) (*models.User, error) {
+	switch id {
+	case 1:
+		return mockUser, nil
+	default:
+		return nil, models.ErrNoRecord
+	}
+}
+
+func (m *UserModel) GetByEmail(email string) (*models.User, error) {
+	switch email {
+	case mockUser.Email:
+		return mockUser, nil
+	default:
+		return nil, models.ErrNoRecord
+	}
+}
+
+func (m *UserModel) UpdateProfile(id int, skill float64, playTimes []string, timeZone string) error {
+	if id != mockUser.ID {
+		return models.ErrNoRecord
+	}
+	return nil
+}
+
+func (m *UserModel) Partners(u *models.User) ([]*models.User, error) {
+	return []*models.User{}, nil
+}
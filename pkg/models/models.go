@@ -2,6 +2,9 @@ package models
 
 import (
 	"errors"
+	"fmt"
+	"math"
+	"sort"
 	"time"
 )
 
@@ -14,14 +17,339 @@ var (
 	// Error for when a user tries to sign up with an email adress that is already
 	// found in the database (not unique)
 	ErrDuplicateEmail = errors.New("models: duplicate email")
+	// Error for when a user tries to book a court at a time which overlaps
+	// with another session on the same court
+	ErrSlotTaken = errors.New("models: time slot already taken")
+	// Error for when a user tries to join the waitlist of a slot twice
+	ErrAlreadyQueued = errors.New("models: already on the waitlist")
+	// Error for when the result of a session is recorded twice
+	ErrResultExists = errors.New("models: result already recorded")
+	// Error for when a user joins the ladder twice
+	ErrAlreadyOnLadder = errors.New("models: already on the ladder")
+	// Error for when a user registers twice for the same tournament
+	ErrAlreadyRegistered = errors.New("models: already registered")
+	// Error for when a lesson is booked outside of the availability of the
+	// coach, or at a time in which the coach already gives another lesson
+	ErrCoachUnavailable = errors.New("models: coach not available")
+	// Error for when more units of an equipment item are rented during a time
+	// slot than the club owns
+	ErrEquipmentUnavailable = errors.New("models: equipment not available")
+	// Error for when a user is invited twice to the same session
+	ErrAlreadyInvited = errors.New("models: already invited")
+	// Error for when a user is invited to a session which already has as many
+	// players as its kind allows
+	ErrSessionFull = errors.New("models: session is full")
+	// Error for when a court is booked outside of its opening hours or during
+	// a blackout
+	ErrCourtClosed = errors.New("models: court closed")
+	// Error for when a session does not fit the booking grid of its court
+	ErrOffGrid = errors.New("models: session off the booking grid")
+	// Error for when a booking breaks the rules of the membership tier of the
+	// user, or the booking rights of the user are suspended
+	ErrBookingRefused = errors.New("models: booking refused")
 )
 
 type Session struct {
-	ID      int
-	Title   string
-	Content string
-	Created time.Time
-	Expires time.Time
+	ID        int
+	UserID    int // user who booked the session
+	UserName  string
+	CourtID   int
+	CourtName string
+	SeriesID  int    // 0 if the session is not part of a series
+	Kind      string // singles or doubles
+	Open      bool   // other members of a similar level can join the session
+	CoachID   int    // coach giving the lesson, 0 if the session is not a lesson
+	CoachName string
+	Guests    bool // played with guests, which are charged the guest rate
+	Price     int  // in cents, computed when the session is booked
+	Title     string
+	Content   string
+	Created   time.Time
+	Start     time.Time
+	End       time.Time
+	Status    string
+	// user who cancelled the session and when, if it was cancelled
+	CancelledBy     int
+	CancelledByName string
+	CancelledAt     time.Time
+	CancelReason    string
+	// when a player checked in on the court, zero if nobody did. Sessions
+	// which ended without a check-in are marked as no-shows, they can also
+	// be flagged by an admin or a co-player (NoShowBy is 0 if the session was
+	// marked automatically). An admin can clear the no-show, the session is
+	// not marked again after that
+	CheckedIn       time.Time
+	NoShow          bool
+	NoShowBy        int
+	NoShowClearedBy int
+}
+
+// SessionChange records the court, the title and the time slot of a session
+// before it was edited, the current values are stored in the session itself
+type SessionChange struct {
+	ID        int
+	SessionID int
+	UserID    int // user who edited the session
+	UserName  string
+	CourtID   int
+	CourtName string
+	Title     string
+	Content   string
+	Start     time.Time
+	End       time.Time
+	Changed   time.Time
+}
+
+// Status of a session
+const (
+	StatusBooked    = "booked"
+	StatusCancelled = "cancelled"
+)
+
+// Filters of the bookings of a user: the booked sessions which did not end
+// yet, the booked sessions which already ended and the cancelled sessions
+const (
+	BookingsUpcoming  = "upcoming"
+	BookingsPast      = "past"
+	BookingsCancelled = "cancelled"
+)
+
+// Cancelled reports if the session was cancelled
+func (s *Session) Cancelled() bool {
+	return s.Status == StatusCancelled
+}
+
+// Upcoming reports if the session has not started yet
+func (s *Session) Upcoming() bool {
+	return time.Now().Before(s.Start)
+}
+
+// Lesson reports if the session is a lesson given by a coach
+func (s *Session) Lesson() bool {
+	return s.CoachID != 0
+}
+
+// Kind of a session, it determines how many players take part in it
+const (
+	KindSingles = "singles"
+	KindDoubles = "doubles"
+)
+
+// MaxPlayers returns the number of players of the session, including the user
+// who booked it
+func (s *Session) MaxPlayers() int {
+	if s.Kind == KindDoubles {
+		return 4
+	}
+	return 2
+}
+
+// A Participant is a user invited to play in a session by the user who
+// booked it
+type Participant struct {
+	SessionID int
+	UserID    int
+	UserName  string
+	Status    string
+	Invited   time.Time
+	Responded time.Time // zero while the invitation is pending
+}
+
+// An Equipment item can be rented together with a court, e.g. the ball
+// machine or rackets
+type Equipment struct {
+	ID       int
+	Name     string
+	Quantity int // units owned by the club
+}
+
+// A Rental is a number of units of an equipment item rented for a session
+type Rental struct {
+	SessionID     int
+	EquipmentID   int
+	EquipmentName string
+	Quantity      int
+}
+
+// A MatchResult is the outcome of a played session. The score is written
+// from the point of view of the home side, the user who booked the session
+// (and the partner in doubles), the other players are the away side
+type MatchResult struct {
+	ID              int
+	SessionID       int
+	Score           string // like '6-4 3-6 7-6(7-5)'
+	BestOf          int
+	FinalSet        string
+	Winner          string // home or away
+	Status          string // pending until another player confirms the result
+	ReportedBy      int
+	ReportedByName  string
+	ConfirmedBy     int // 0 while the result is pending
+	ConfirmedByName string
+	Created         time.Time
+}
+
+// Status of the result of a session, a result reported by a player only
+// counts once another player (or an admin) confirmed it
+const (
+	ResultPending   = "pending"
+	ResultConfirmed = "confirmed"
+)
+
+// Pending reports if the result still has to be confirmed
+func (r *MatchResult) Pending() bool {
+	return r.Status == ResultPending
+}
+
+// A Rating is the standing of a player on the singles ladder of the club
+type Rating struct {
+	UserID   int
+	UserName string
+	Rank     int // rung of the ladder, 1 is the top
+	Rating   int // Elo rating
+	Matches  int
+	Wins     int
+	Joined   time.Time
+}
+
+// A RatingChange records how a rated match changed the rating of a player
+type RatingChange struct {
+	UserID       int
+	SessionID    int
+	OpponentID   int
+	OpponentName string
+	Won          bool
+	Before       int
+	After        int
+	Created      time.Time
+}
+
+// A Tournament is a competition of the club, its matches are played as
+// singles sessions booked automatically
+type Tournament struct {
+	ID            int
+	Name          string
+	Format        string    // single elimination or round robin
+	Status        string    // registration, running or finished
+	Start         time.Time // day of the first round, one round is played per week
+	MatchDuration time.Duration
+	Created       time.Time
+}
+
+// Formats of a tournament
+const (
+	FormatSingleElimination = "single-elimination"
+	FormatRoundRobin        = "round-robin"
+)
+
+// Status of a tournament
+const (
+	TournamentRegistration = "registration"
+	TournamentRunning      = "running"
+	TournamentFinished     = "finished"
+)
+
+// A TournamentEntry is a player registered for a tournament
+type TournamentEntry struct {
+	TournamentID int
+	UserID       int
+	UserName     string
+	Seed         int // 0 until the draw is made
+	Created      time.Time
+}
+
+// A TournamentMatch is a match of the draw of a tournament. The players of
+// the later rounds of a knockout draw are only known once the previous
+// matches were played
+type TournamentMatch struct {
+	ID           int
+	TournamentID int
+	Round        int // starting at 1
+	Position     int // position of the match in its round, starting at 0
+	HomeID       int // 0 while unknown
+	HomeName     string
+	AwayID       int // 0 while unknown, or if the home player has a bye
+	AwayName     string
+	WinnerID     int // 0 while the match was not played
+	SessionID    int // 0 while the match is not scheduled
+	Score        string
+}
+
+// Bye reports if the home player advances without playing the match
+func (m *TournamentMatch) Bye() bool {
+	return m.WinnerID != 0 && m.AwayID == 0
+}
+
+// Ready reports if both players of the match are known but it was not
+// played yet
+func (m *TournamentMatch) Ready() bool {
+	return m.HomeID != 0 && m.AwayID != 0 && m.WinnerID == 0
+}
+
+// Status of the invitation of a participant
+const (
+	ParticipantInvited  = "invited"
+	ParticipantAccepted = "accepted"
+	ParticipantDeclined = "declined"
+)
+
+type Court struct {
+	ID         int
+	Name       string
+	Surface    string
+	Outdoor    bool            // outdoor courts are closed when it rains
+	SlotLength time.Duration   // sessions start on a grid of this step from midnight, 0 if any start is allowed
+	Durations  []time.Duration // allowed durations of a session in ascending order, any if empty
+	Created    time.Time
+}
+
+// Choices for the booking grid of a court
+var (
+	SlotLengths      = []time.Duration{15 * time.Minute, 30 * time.Minute, time.Hour}
+	SessionDurations = []time.Duration{30 * time.Minute, 45 * time.Minute, time.Hour, 90 * time.Minute, 2 * time.Hour}
+)
+
+// OnGrid reports if a session can start at t on the court, the grid starts at
+// midnight in the time zone of t and follows the wall clock, like the rows of
+// the calendar
+func (c *Court) OnGrid(t time.Time) bool {
+	if c.SlotLength <= 0 {
+		return true
+	}
+	return clockOffset(t)%c.SlotLength == 0
+}
+
+// Snap returns the time on the grid of the court which is closest to t
+func (c *Court) Snap(t time.Time) time.Time {
+	if c.SlotLength <= 0 {
+		return t
+	}
+	offset := clockOffset(t)
+	snapped := offset - offset%c.SlotLength
+	if offset%c.SlotLength >= c.SlotLength/2 {
+		snapped += c.SlotLength
+	}
+	return wallClock(t, snapped)
+}
+
+// clockOffset returns the time which the wall clock shows at t as an offset
+// from midnight, it is the inverse of wallClock
+func clockOffset(t time.Time) time.Duration {
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute +
+		time.Duration(t.Second())*time.Second + time.Duration(t.Nanosecond())
+}
+
+// AllowedDuration reports if a session on the court can last d
+func (c *Court) AllowedDuration(d time.Duration) bool {
+	if len(c.Durations) == 0 {
+		return true
+	}
+	for _, allowed := range c.Durations {
+		if d == allowed {
+			return true
+		}
+	}
+	return false
 }
 
 type User struct {
@@ -29,5 +357,402 @@ type User struct {
 	Name           string
 	Email          string
 	HashedPassword []byte
+	Role           string
+	Tier           string   // name of the membership tier
+	Skill          float64  // NTRP rating, 0 if the user is not rated
+	PlayTimes      []string // preferred times to play, see PlayTimes
+	TimeZone       string   // time zone in which times are shown to the user, empty for the one of the club
 	Created        time.Time
 }
+
+// Range of the NTRP ratings, in steps of 0.5
+const (
+	MinSkill = 1.0
+	MaxSkill = 7.0
+)
+
+// SkillTolerance is the largest difference between the NTRP ratings of two
+// members which are still considered to be of a similar level
+const SkillTolerance = 0.5
+
+// Times of the week at which a user prefers to play
+var PlayTimes = []string{
+	"weekday-morning", "weekday-afternoon", "weekday-evening",
+	"weekend-morning", "weekend-afternoon", "weekend-evening",
+}
+
+// Rated reports if the user has set an NTRP rating
+func (u *User) Rated() bool {
+	return u.Skill >= MinSkill
+}
+
+// SimilarLevel reports if both users are rated and their ratings differ by
+// at most SkillTolerance
+func (u *User) SimilarLevel(other *User) bool {
+	if !u.Rated() || !other.Rated() {
+		return false
+	}
+	return math.Abs(u.Skill-other.Skill) <= SkillTolerance
+}
+
+// Compatible reports if both users are of a similar level and share at least
+// one preferred time to play
+func (u *User) Compatible(other *User) bool {
+	if !u.SimilarLevel(other) {
+		return false
+	}
+	for _, a := range u.PlayTimes {
+		for _, b := range other.PlayTimes {
+			if a == b {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Roles of the users
+const (
+	RoleMember = "member"
+	RoleCoach  = "coach"
+	RoleAdmin  = "admin"
+)
+
+// IsAdmin reports if the user is an administrator of the club
+func (u *User) IsAdmin() bool {
+	return u.Role == RoleAdmin
+}
+
+// IsCoach reports if the user is a coach who gives lessons at the club
+func (u *User) IsCoach() bool {
+	return u.Role == RoleCoach
+}
+
+// A CoachWindow is a period in which a coach is available to give lessons,
+// the lessons booked with the coach have to lie completely inside a window
+type CoachWindow struct {
+	ID        int
+	CoachID   int
+	CoachName string
+	Start     time.Time
+	End       time.Time
+	Created   time.Time
+}
+
+// Membership tiers, every user belongs to one of them
+const (
+	TierFull   = "full"
+	TierJunior = "junior"
+	TierSocial = "social"
+	TierGuest  = "guest"
+)
+
+// A Tier is a kind of membership of the club, it sets how the members of the
+// tier can book the courts
+type Tier struct {
+	Name    string
+	Horizon int           // how many days ahead sessions can be booked, 0 if there is no limit
+	Opens   time.Duration // earliest start of a session, as offset from midnight
+	Closes  time.Duration // latest end of a session, 0 if sessions can be booked at any time
+	Quota   Quota
+}
+
+// Refusal returns why a member of the tier cannot book the slot at the time
+// now, or an empty string if the slot can be booked. The allowed hours are
+// taken on the wall clock in the time zone of the slot
+func (t *Tier) Refusal(slot Slot, now time.Time) string {
+	if t.Horizon > 0 && slot.Start.After(now.AddDate(0, 0, t.Horizon)) {
+		return fmt.Sprintf("%s members can only book up to %d days ahead", t.Name, t.Horizon)
+	}
+	if t.Closes == 0 {
+		return ""
+	}
+	opens, closes := wallClock(slot.Start, t.Opens), wallClock(slot.Start, t.Closes)
+	if slot.Start.Before(opens) || slot.End.After(closes) {
+		return fmt.Sprintf("%s members can only play between %s and %s", t.Name,
+			opens.Format("15:04"), closes.Format("15:04"))
+	}
+	return ""
+}
+
+// Quota limits the bookings of a user, a limit of 0 disables it
+type Quota struct {
+	MaxActive      int           // upcoming bookings, a series counts as one booking
+	MaxWeekly      time.Duration // booked time per week
+	MaxWeeklyPrime int           // prime-time sessions per week
+}
+
+// A BookingCheck verifies the rules which the bookings of a user have to
+// follow. It is run inside the booking transaction while the user is locked,
+// so that concurrent bookings of the same user are checked one after the
+// other
+type BookingCheck struct {
+	UserID int
+	From   time.Time // the booked sessions of the user ending after From are verified
+	// Verify returns ErrBookingRefused if the booking breaks a rule, given the
+	// sessions already booked by the user
+	Verify func(booked []*Session) error
+}
+
+// A Slot is a time range, e.g. the time in which a session takes place
+type Slot struct {
+	Start time.Time
+	End   time.Time
+}
+
+// From returns the part of the slot which is not before t, the returned slot
+// is empty (its end is not after its start) if the slot ends before t
+func (s Slot) From(t time.Time) Slot {
+	if s.Start.Before(t) {
+		s.Start = t
+	}
+	return s
+}
+
+// Frequencies at which the sessions of a series are repeated
+const (
+	FrequencyWeekly   = "weekly"
+	FrequencyBiweekly = "biweekly"
+)
+
+// Maximum number of sessions that a series can create
+const MaxOccurrences = 52
+
+// A Series is a set of sessions which are repeated at a given frequency on the
+// same court, either until a given date or for a given number of times
+type Series struct {
+	ID        int
+	UserID    int // user who created the series
+	CourtID   int
+	CourtName string
+	Kind      string // kind of all the sessions of the series
+	Guests    bool   // all the sessions of the series are played with guests
+	Title     string
+	Frequency string
+	Until     time.Time // zero if the series ends after Count sessions
+	Count     int       // 0 if the series ends at Until
+	Created   time.Time
+}
+
+// Slots returns the time slots of all the sessions of the series, the first
+// session takes place at the slot first. At most MaxOccurrences slots are
+// returned
+func (s *Series) Slots(first Slot) []Slot {
+	days := 7
+	if s.Frequency == FrequencyBiweekly {
+		days = 14
+	}
+	slots := []Slot{}
+	for i := 0; i < MaxOccurrences; i++ {
+		if s.Count > 0 && i >= s.Count {
+			break
+		}
+		slot := Slot{
+			Start: first.Start.AddDate(0, 0, i*days),
+			End:   first.End.AddDate(0, 0, i*days),
+		}
+		// Until is a date (at UTC), the sessions on that day in the time
+		// zone of the slots are still included
+		if !s.Until.IsZero() && !slot.Start.Before(time.Date(s.Until.Year(), s.Until.Month(), s.Until.Day()+1, 0, 0, 0, 0, slot.Start.Location())) {
+			break
+		}
+		slots = append(slots, slot)
+	}
+	return slots
+}
+
+// OpeningHours holds one opening window of a court on a given weekday, the
+// times are the offsets from midnight (e.g. 8*time.Hour for 08:00)
+type OpeningHours struct {
+	ID      int
+	CourtID int
+	Weekday time.Weekday
+	Opens   time.Duration
+	Closes  time.Duration
+}
+
+// A Blackout is a one-off period in which a court cannot be booked, e.g.
+// because of a tournament or resurfacing works
+type Blackout struct {
+	ID        int
+	CourtID   int // 0 if the blackout applies to all courts
+	CourtName string
+	Start     time.Time
+	End       time.Time
+	Reason    string
+	Created   time.Time
+}
+
+// Availability holds the opening hours and the blackouts of the courts, it
+// tells if a court can be booked for a given slot
+type Availability struct {
+	Hours     []*OpeningHours
+	Blackouts []*Blackout
+}
+
+// Closed returns why the court cannot be booked during the slot, or an empty
+// string if the court is open during the whole slot. A court without any
+// opening hours is always open. A slot which crosses midnight has to be
+// inside the opening hours of both days
+func (a *Availability) Closed(courtID int, slot Slot) string {
+	for _, b := range a.Blackouts {
+		if (b.CourtID == 0 || b.CourtID == courtID) && b.Start.Before(slot.End) && b.End.After(slot.Start) {
+			return b.Reason
+		}
+	}
+	configured := false
+	for _, h := range a.Hours {
+		if h.CourtID == courtID {
+			configured = true
+			break
+		}
+	}
+	if !configured {
+		return ""
+	}
+	for start := slot.Start; start.Before(slot.End); {
+		next := time.Date(start.Year(), start.Month(), start.Day()+1, 0, 0, 0, 0, start.Location())
+		end := slot.End
+		if next.Before(end) {
+			end = next
+		}
+		if !a.open(courtID, Slot{Start: start, End: end}) {
+			return "Outside of the opening hours"
+		}
+		start = next
+	}
+	return ""
+}
+
+// wallClock returns the time which the wall clock shows at the offset from
+// midnight on the day of t, in the time zone of t. Unlike adding the offset
+// to midnight, the result does not move by an hour on the days on which
+// daylight saving time starts or ends
+func wallClock(t time.Time, offset time.Duration) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, int(offset.Seconds()), 0, t.Location())
+}
+
+// open reports if the slot, which lies within a single day, is inside one of
+// the opening windows of the court on that day. The windows are taken on the
+// wall clock, so that they do not move on the days on which daylight saving
+// time starts or ends
+func (a *Availability) open(courtID int, slot Slot) bool {
+	for _, h := range a.Hours {
+		if h.CourtID != courtID || h.Weekday != slot.Start.Weekday() {
+			continue
+		}
+		if !slot.Start.Before(wallClock(slot.Start, h.Opens)) && !slot.End.After(wallClock(slot.Start, h.Closes)) {
+			return true
+		}
+	}
+	return false
+}
+
+// Time bands of the prices, peak times are usually more expensive
+const (
+	BandPeak    = "peak"
+	BandOffPeak = "off-peak"
+)
+
+// A PriceRule sets the hourly rates of a court during a time band on a given
+// weekday, the times are the offsets from midnight like in OpeningHours
+type PriceRule struct {
+	ID         int
+	CourtID    int // 0 if the rule applies to all courts
+	Weekday    time.Weekday
+	Starts     time.Duration
+	Ends       time.Duration
+	Band       string
+	MemberRate int // per hour, in cents
+	GuestRate  int // per hour, in cents, charged for sessions with guests
+}
+
+// Pricing holds the price rules of the courts, it computes the price of a
+// session when it is booked
+type Pricing struct {
+	Rules []*PriceRule
+}
+
+// Rule returns the rule which applies to the court at the time t, or nil if
+// there is none. The rules of the court take precedence over the rules which
+// apply to all courts. The time bands are taken on the wall clock
+func (p *Pricing) Rule(courtID int, t time.Time) *PriceRule {
+	var rule *PriceRule
+	for _, r := range p.Rules {
+		if r.Weekday != t.Weekday() || t.Before(wallClock(t, r.Starts)) || !t.Before(wallClock(t, r.Ends)) {
+			continue
+		}
+		if r.CourtID == courtID {
+			return r
+		}
+		if r.CourtID == 0 {
+			rule = r
+		}
+	}
+	return rule
+}
+
+// Price returns the price in cents of booking the court for the slot. The
+// slot is split at the boundaries of the time bands and every part is charged
+// at the hourly rate of its band, the parts which are not covered by any rule
+// are free
+func (p *Pricing) Price(courtID int, slot Slot, guests bool) int {
+	inside := func(t time.Time) bool {
+		return t.After(slot.Start) && t.Before(slot.End)
+	}
+	cuts := []time.Time{slot.Start, slot.End}
+	day := time.Date(slot.Start.Year(), slot.Start.Month(), slot.Start.Day(), 0, 0, 0, 0, slot.Start.Location())
+	for ; day.Before(slot.End); day = day.AddDate(0, 0, 1) {
+		if inside(day) {
+			cuts = append(cuts, day)
+		}
+		for _, r := range p.Rules {
+			for _, t := range []time.Time{wallClock(day, r.Starts), wallClock(day, r.Ends)} {
+				if inside(t) {
+					cuts = append(cuts, t)
+				}
+			}
+		}
+	}
+	sort.Slice(cuts, func(i, j int) bool { return cuts[i].Before(cuts[j]) })
+
+	var price int64
+	for i := 0; i+1 < len(cuts); i++ {
+		r := p.Rule(courtID, cuts[i])
+		if r == nil {
+			continue
+		}
+		rate := r.MemberRate
+		if guests {
+			rate = r.GuestRate
+		}
+		price += int64(rate) * int64(cuts[i+1].Sub(cuts[i]))
+	}
+	return int(price / int64(time.Hour))
+}
+
+// A WaitlistEntry queues a user for a time slot on a court which is already
+// booked. When the session booking the slot is cancelled, the first user of
+// the waitlist gets the slot
+type WaitlistEntry struct {
+	ID       int
+	CourtID  int
+	Start    time.Time
+	End      time.Time
+	UserID   int
+	UserName string
+	Kind     string // kind of the session booked for the user
+	Guests   bool   // the session booked for the user is charged the guest rate
+	Title    string // title of the session booked for the user
+	Created  time.Time
+}
+
+// A Notification is a message for a user, which is displayed the next time
+// the user visits the website
+type Notification struct {
+	ID      int
+	UserID  int
+	Message string
+	Link    string // optional URL with more details
+	Created time.Time
+}
@@ -0,0 +1,408 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSeriesSlots(t *testing.T) {
+	// The first session takes place on Tuesday 17 May 2022 from 19:00 to 20:30
+	first := Slot{
+		Start: time.Date(2022, 5, 17, 19, 0, 0, 0, time.UTC),
+		End:   time.Date(2022, 5, 17, 20, 30, 0, 0, time.UTC),
+	}
+	tests := []struct {
+		name     string
+		series   *Series
+		expected int       // number of slots
+		last     time.Time // start of the last slot
+	}{
+		{
+			name:     "WeeklyCount",
+			series:   &Series{Frequency: FrequencyWeekly, Count: 4},
+			expected: 4,
+			last:     time.Date(2022, 6, 7, 19, 0, 0, 0, time.UTC),
+		},
+		{
+			name:     "BiweeklyCount",
+			series:   &Series{Frequency: FrequencyBiweekly, Count: 3},
+			expected: 3,
+			last:     time.Date(2022, 6, 14, 19, 0, 0, 0, time.UTC),
+		},
+		{
+			name:     "WeeklyUntilIncluded",
+			series:   &Series{Frequency: FrequencyWeekly, Until: time.Date(2022, 5, 31, 0, 0, 0, 0, time.UTC)},
+			expected: 3,
+			last:     time.Date(2022, 5, 31, 19, 0, 0, 0, time.UTC),
+		},
+		{
+			name:     "BiweeklyUntil",
+			series:   &Series{Frequency: FrequencyBiweekly, Until: time.Date(2022, 6, 13, 0, 0, 0, 0, time.UTC)},
+			expected: 2,
+			last:     time.Date(2022, 5, 31, 19, 0, 0, 0, time.UTC),
+		},
+		{
+			name:     "Limit",
+			series:   &Series{Frequency: FrequencyWeekly, Until: time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)},
+			expected: MaxOccurrences,
+			last:     time.Date(2023, 5, 9, 19, 0, 0, 0, time.UTC),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			slots := tt.series.Slots(first)
+			if len(slots) != tt.expected {
+				t.Fatalf("expected %d slots; got %d", tt.expected, len(slots))
+			}
+			last := slots[len(slots)-1]
+			if !last.Start.Equal(tt.last) {
+				t.Errorf("expected last slot to start at %v; got %v", tt.last, last.Start)
+			}
+			if d := last.End.Sub(last.Start); d != 90*time.Minute {
+				t.Errorf("expected slots to last %v; got %v", 90*time.Minute, d)
+			}
+		})
+	}
+}
+
+func TestAvailabilityClosed(t *testing.T) {
+	// Court 1 opens on Tuesdays from 08:00 to 12:00 and from 14:00 to 22:00,
+	// court 2 has no opening hours, court 3 opens on Fridays from 20:00 until
+	// 02:00 on Saturdays. Court 1 is closed for a tournament on Tuesday 17 May
+	// 2022 from 18:00 to 20:00 and all courts are closed on Wednesday 18 May
+	// 2022
+	a := &Availability{
+		Hours: []*OpeningHours{
+			{CourtID: 1, Weekday: time.Tuesday, Opens: 8 * time.Hour, Closes: 12 * time.Hour},
+			{CourtID: 1, Weekday: time.Tuesday, Opens: 14 * time.Hour, Closes: 22 * time.Hour},
+			{CourtID: 3, Weekday: time.Friday, Opens: 20 * time.Hour, Closes: 24 * time.Hour},
+			{CourtID: 3, Weekday: time.Saturday, Opens: 0, Closes: 2 * time.Hour},
+		},
+		Blackouts: []*Blackout{
+			{
+				CourtID: 1,
+				Start:   time.Date(2022, 5, 17, 18, 0, 0, 0, time.UTC),
+				End:     time.Date(2022, 5, 17, 20, 0, 0, 0, time.UTC),
+				Reason:  "Tournament",
+			},
+			{
+				Start:  time.Date(2022, 5, 18, 0, 0, 0, 0, time.UTC),
+				End:    time.Date(2022, 5, 19, 0, 0, 0, 0, time.UTC),
+				Reason: "Holiday",
+			},
+		},
+	}
+	// slot returns a slot on a day of May 2022 between two hours
+	slot := func(day, from, to int) Slot {
+		return Slot{
+			Start: time.Date(2022, 5, day, from, 0, 0, 0, time.UTC),
+			End:   time.Date(2022, 5, day, to, 0, 0, 0, time.UTC),
+		}
+	}
+	tests := []struct {
+		name     string
+		court    int
+		slot     Slot
+		expected string
+	}{
+		{name: "Open", court: 1, slot: slot(17, 8, 10), expected: ""},
+		{name: "SecondWindow", court: 1, slot: slot(17, 20, 22), expected: ""},
+		{name: "BeforeOpening", court: 1, slot: slot(17, 7, 9), expected: "Outside of the opening hours"},
+		{name: "Lunch", court: 1, slot: slot(17, 11, 13), expected: "Outside of the opening hours"},
+		{name: "OtherWeekday", court: 1, slot: slot(16, 9, 10), expected: "Outside of the opening hours"},
+		{name: "Blackout", court: 1, slot: slot(17, 19, 21), expected: "Tournament"},
+		{name: "NoHours", court: 2, slot: slot(17, 19, 21), expected: ""},
+		{name: "AllCourts", court: 2, slot: slot(18, 10, 11), expected: "Holiday"},
+		{name: "OverMidnight", court: 3, slot: Slot{Start: time.Date(2022, 5, 20, 23, 0, 0, 0, time.UTC), End: time.Date(2022, 5, 21, 1, 0, 0, 0, time.UTC)}, expected: ""},
+		{name: "AfterMidnightClosing", court: 3, slot: Slot{Start: time.Date(2022, 5, 20, 23, 0, 0, 0, time.UTC), End: time.Date(2022, 5, 21, 3, 0, 0, 0, time.UTC)}, expected: "Outside of the opening hours"},
+		{name: "IntoClosedDay", court: 1, slot: Slot{Start: time.Date(2022, 5, 16, 23, 0, 0, 0, time.UTC), End: time.Date(2022, 5, 17, 9, 0, 0, 0, time.UTC)}, expected: "Outside of the opening hours"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			closed := a.Closed(tt.court, tt.slot)
+			if closed != tt.expected {
+				t.Errorf("expected %q; got %q", tt.expected, closed)
+			}
+		})
+	}
+}
+
+func TestCourtGrid(t *testing.T) {
+	c := &Court{SlotLength: 30 * time.Minute, Durations: []time.Duration{time.Hour, 90 * time.Minute}}
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		court   *Court
+		start   time.Time
+		onGrid  bool
+		snapped time.Time
+	}{
+		{name: "OnGrid", court: c, start: time.Date(2022, 5, 17, 9, 30, 0, 0, time.UTC), onGrid: true, snapped: time.Date(2022, 5, 17, 9, 30, 0, 0, time.UTC)},
+		{name: "SnapDown", court: c, start: time.Date(2022, 5, 17, 9, 10, 0, 0, time.UTC), onGrid: false, snapped: time.Date(2022, 5, 17, 9, 0, 0, 0, time.UTC)},
+		{name: "SnapUp", court: c, start: time.Date(2022, 5, 17, 9, 15, 0, 0, time.UTC), onGrid: false, snapped: time.Date(2022, 5, 17, 9, 30, 0, 0, time.UTC)},
+		{name: "TimeZone", court: c, start: time.Date(2022, 5, 17, 9, 30, 0, 0, berlin), onGrid: true, snapped: time.Date(2022, 5, 17, 9, 30, 0, 0, berlin)},
+		{name: "NoGrid", court: &Court{}, start: time.Date(2022, 5, 17, 9, 10, 0, 0, time.UTC), onGrid: true, snapped: time.Date(2022, 5, 17, 9, 10, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if onGrid := tt.court.OnGrid(tt.start); onGrid != tt.onGrid {
+				t.Errorf("expected %t; got %t", tt.onGrid, onGrid)
+			}
+			if snapped := tt.court.Snap(tt.start); !snapped.Equal(tt.snapped) {
+				t.Errorf("expected %v; got %v", tt.snapped, snapped)
+			}
+		})
+	}
+
+	durations := []struct {
+		name     string
+		court    *Court
+		d        time.Duration
+		expected bool
+	}{
+		{name: "Allowed", court: c, d: 90 * time.Minute, expected: true},
+		{name: "NotAllowed", court: c, d: 30 * time.Minute, expected: false},
+		{name: "AnyDuration", court: &Court{}, d: 45 * time.Minute, expected: true},
+	}
+	for _, tt := range durations {
+		t.Run(tt.name, func(t *testing.T) {
+			if allowed := tt.court.AllowedDuration(tt.d); allowed != tt.expected {
+				t.Errorf("expected %t; got %t", tt.expected, allowed)
+			}
+		})
+	}
+}
+
+func TestCourtGridDST(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// daylight saving time starts in Berlin on 29 March 2026, a grid of 45
+	// minutes still follows the wall clock on that day
+	c := &Court{SlotLength: 45 * time.Minute}
+	at := func(hour, min int) time.Time {
+		return time.Date(2026, 3, 29, hour, min, 0, 0, berlin)
+	}
+	tests := []struct {
+		name    string
+		start   time.Time
+		onGrid  bool
+		snapped time.Time
+	}{
+		{name: "AfterTheChange", start: at(3, 0), onGrid: true, snapped: at(3, 0)},
+		{name: "Morning", start: at(9, 0), onGrid: true, snapped: at(9, 0)},
+		{name: "SnapDown", start: at(9, 10), onGrid: false, snapped: at(9, 0)},
+		{name: "SnapUp", start: at(9, 30), onGrid: false, snapped: at(9, 45)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if onGrid := c.OnGrid(tt.start); onGrid != tt.onGrid {
+				t.Errorf("expected %t; got %t", tt.onGrid, onGrid)
+			}
+			if snapped := c.Snap(tt.start); !snapped.Equal(tt.snapped) {
+				t.Errorf("expected %v; got %v", tt.snapped, snapped)
+			}
+		})
+	}
+}
+
+func TestSlotFrom(t *testing.T) {
+	slot := Slot{
+		Start: time.Date(2022, 5, 17, 9, 0, 0, 0, time.UTC),
+		End:   time.Date(2022, 5, 17, 12, 0, 0, 0, time.UTC),
+	}
+	tests := []struct {
+		name     string
+		t        time.Time
+		expected time.Time // start of the returned slot
+		empty    bool
+	}{
+		{name: "Future", t: time.Date(2022, 5, 17, 8, 0, 0, 0, time.UTC), expected: slot.Start},
+		{name: "Started", t: time.Date(2022, 5, 17, 10, 0, 0, 0, time.UTC), expected: time.Date(2022, 5, 17, 10, 0, 0, 0, time.UTC)},
+		{name: "Over", t: time.Date(2022, 5, 17, 13, 0, 0, 0, time.UTC), expected: time.Date(2022, 5, 17, 13, 0, 0, 0, time.UTC), empty: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			from := slot.From(tt.t)
+			if !from.Start.Equal(tt.expected) {
+				t.Errorf("expected %v; got %v", tt.expected, from.Start)
+			}
+			if !from.End.Equal(slot.End) {
+				t.Errorf("expected %v; got %v", slot.End, from.End)
+			}
+			if empty := !from.End.After(from.Start); empty != tt.empty {
+				t.Errorf("expected %t; got %t", tt.empty, empty)
+			}
+		})
+	}
+}
+
+func TestUserCompatible(t *testing.T) {
+	user := &User{Skill: 3.5, PlayTimes: []string{"weekday-evening", "weekend-morning"}}
+	tests := []struct {
+		name     string
+		other    *User
+		expected bool
+	}{
+		{name: "SameLevelSharedTime", other: &User{Skill: 3.5, PlayTimes: []string{"weekend-morning"}}, expected: true},
+		{name: "SimilarLevel", other: &User{Skill: 4.0, PlayTimes: []string{"weekday-evening"}}, expected: true},
+		{name: "DifferentLevel", other: &User{Skill: 4.5, PlayTimes: []string{"weekday-evening"}}, expected: false},
+		{name: "NoSharedTime", other: &User{Skill: 3.5, PlayTimes: []string{"weekday-morning"}}, expected: false},
+		{name: "NotRated", other: &User{PlayTimes: []string{"weekday-evening"}}, expected: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ok := user.Compatible(tt.other)
+			if ok != tt.expected {
+				t.Errorf("expected %t; got %t", tt.expected, ok)
+			}
+		})
+	}
+}
+
+func TestPricingPrice(t *testing.T) {
+	// On Tuesdays all courts are off-peak until 17:00 and peak from 17:00 to
+	// 22:00, court 2 is cheaper at peak times. Nothing is charged on other
+	// weekdays
+	p := &Pricing{
+		Rules: []*PriceRule{
+			{Weekday: time.Tuesday, Starts: 8 * time.Hour, Ends: 17 * time.Hour, Band: BandOffPeak, MemberRate: 1000, GuestRate: 1500},
+			{Weekday: time.Tuesday, Starts: 17 * time.Hour, Ends: 22 * time.Hour, Band: BandPeak, MemberRate: 2000, GuestRate: 3000},
+			{CourtID: 2, Weekday: time.Tuesday, Starts: 17 * time.Hour, Ends: 22 * time.Hour, Band: BandPeak, MemberRate: 1600, GuestRate: 2400},
+		},
+	}
+	// slot returns a slot on a day of May 2022 between two times
+	slot := func(day int, from, to time.Duration) Slot {
+		midnight := time.Date(2022, 5, day, 0, 0, 0, 0, time.UTC)
+		return Slot{Start: midnight.Add(from), End: midnight.Add(to)}
+	}
+	tests := []struct {
+		name     string
+		court    int
+		slot     Slot
+		guests   bool
+		expected int
+	}{
+		{name: "OffPeak", court: 1, slot: slot(17, 10*time.Hour, 11*time.Hour), expected: 1000},
+		{name: "Peak", court: 1, slot: slot(17, 18*time.Hour, 19*time.Hour+30*time.Minute), expected: 3000},
+		{name: "Guests", court: 1, slot: slot(17, 18*time.Hour, 19*time.Hour), guests: true, expected: 3000},
+		{name: "AcrossBands", court: 1, slot: slot(17, 16*time.Hour+30*time.Minute, 17*time.Hour+30*time.Minute), expected: 1500},
+		{name: "CourtRule", court: 2, slot: slot(17, 18*time.Hour, 19*time.Hour), expected: 1600},
+		{name: "NoRule", court: 1, slot: slot(18, 18*time.Hour, 19*time.Hour), expected: 0},
+		{name: "PartlyCovered", court: 1, slot: slot(17, 21*time.Hour, 23*time.Hour), expected: 2000},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			price := p.Price(tt.court, tt.slot, tt.guests)
+			if price != tt.expected {
+				t.Errorf("expected %d; got %d", tt.expected, price)
+			}
+		})
+	}
+}
+
+func TestPricingPriceDST(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// On Sundays all courts cost 10.00 per hour from 08:00 to 20:00 on the
+	// wall clock, also on the days on which daylight saving time starts (29
+	// March 2026) and ends (25 October 2026)
+	p := &Pricing{
+		Rules: []*PriceRule{
+			{Weekday: time.Sunday, Starts: 8 * time.Hour, Ends: 20 * time.Hour, Band: BandOffPeak, MemberRate: 1000, GuestRate: 1500},
+		},
+	}
+	slot := func(month time.Month, day, from, to int) Slot {
+		return Slot{Start: time.Date(2026, month, day, from, 0, 0, 0, berlin), End: time.Date(2026, month, day, to, 0, 0, 0, berlin)}
+	}
+	tests := []struct {
+		name     string
+		slot     Slot
+		expected int
+	}{
+		{name: "StartOfBand", slot: slot(time.March, 29, 8, 9), expected: 1000},
+		{name: "EndOfBand", slot: slot(time.March, 29, 19, 20), expected: 1000},
+		{name: "AfterBand", slot: slot(time.March, 29, 20, 21), expected: 0},
+		{name: "FallBackStart", slot: slot(time.October, 25, 7, 9), expected: 1000},
+		{name: "FallBackEnd", slot: slot(time.October, 25, 19, 21), expected: 1000},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			price := p.Price(1, tt.slot, false)
+			if price != tt.expected {
+				t.Errorf("expected %d; got %d", tt.expected, price)
+			}
+		})
+	}
+}
+
+func TestTierRefusal(t *testing.T) {
+	// Tuesday 17 May 2022 at 12:00
+	now := time.Date(2022, 5, 17, 12, 0, 0, 0, time.UTC)
+	junior := &Tier{Name: TierJunior, Horizon: 7, Opens: 8 * time.Hour, Closes: 19 * time.Hour}
+	full := &Tier{Name: TierFull}
+	// slot returns a slot on a day of May 2022 between two hours
+	slot := func(day, from, to int) Slot {
+		return Slot{
+			Start: time.Date(2022, 5, day, from, 0, 0, 0, time.UTC),
+			End:   time.Date(2022, 5, day, to, 0, 0, 0, time.UTC),
+		}
+	}
+	tests := []struct {
+		name     string
+		tier     *Tier
+		slot     Slot
+		expected bool // the slot can be booked
+	}{
+		{name: "Allowed", tier: junior, slot: slot(18, 17, 19), expected: true},
+		{name: "BeyondHorizon", tier: junior, slot: slot(25, 10, 11), expected: false},
+		{name: "TooEarly", tier: junior, slot: slot(18, 7, 8), expected: false},
+		{name: "TooLate", tier: junior, slot: slot(18, 18, 20), expected: false},
+		{name: "NoLimits", tier: full, slot: slot(31, 21, 23), expected: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ok := tt.tier.Refusal(tt.slot, now) == ""
+			if ok != tt.expected {
+				t.Errorf("expected %t; got %t", tt.expected, ok)
+			}
+		})
+	}
+}
+
+func TestTierRefusalDST(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// daylight saving time starts in Berlin on Sunday 29 March 2026, the
+	// allowed hours stay on the wall clock
+	now := time.Date(2026, 3, 27, 12, 0, 0, 0, berlin)
+	junior := &Tier{Name: TierJunior, Horizon: 7, Opens: 8 * time.Hour, Closes: 19 * time.Hour}
+	slot := func(from, to int) Slot {
+		return Slot{Start: time.Date(2026, 3, 29, from, 0, 0, 0, berlin), End: time.Date(2026, 3, 29, to, 0, 0, 0, berlin)}
+	}
+	tests := []struct {
+		name     string
+		slot     Slot
+		expected string
+	}{
+		{name: "Opens", slot: slot(8, 9), expected: ""},
+		{name: "Closes", slot: slot(18, 19), expected: ""},
+		{name: "TooEarly", slot: slot(7, 8), expected: "junior members can only play between 08:00 and 19:00"},
+		{name: "TooLate", slot: slot(19, 20), expected: "junior members can only play between 08:00 and 19:00"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if msg := junior.Refusal(tt.slot, now); msg != tt.expected {
+				t.Errorf("expected %q; got %q", tt.expected, msg)
+			}
+		})
+	}
+}
@@ -0,0 +1,171 @@
+package mysql
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/erodrigufer/GoTennis/pkg/models"
+)
+
+// Define a CoachModel type which wraps a sql.DB connection pool, it handles
+// the availability of the coaches and the lessons booked with them
+type CoachModel struct {
+	DB *sql.DB
+}
+
+// All returns the coaches of the club ordered by name
+func (m *CoachModel) All() ([]*models.User, error) {
+	stmt := `SELECT ` + userColumns + ` FROM users WHERE role = ? ORDER BY name`
+	return queryUsers(m.DB, stmt, models.RoleCoach)
+}
+
+// InsertWindow publishes a period in which the coach is available to give
+// lessons, it returns the id of the newly inserted window
+func (m *CoachModel) InsertWindow(coachID int, start, end time.Time) (int, error) {
+	stmt := `INSERT INTO coach_windows (coach_id, start_time, end_time, created)
+	VALUES(?, ?, ?, UTC_TIMESTAMP())`
+	result, err := m.DB.Exec(stmt, coachID, start.UTC(), end.UTC())
+	if err != nil {
+		return 0, err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	return int(id), nil
+}
+
+// DeleteWindow removes a window of the coach, the lessons already booked in it
+// are kept. If the window does not belong to the coach, models.ErrNoRecord is
+// returned
+func (m *CoachModel) DeleteWindow(id, coachID int) error {
+	result, err := m.DB.Exec(`DELETE FROM coach_windows WHERE id = ? AND coach_id = ?`, id, coachID)
+	if err != nil {
+		return err
+	}
+	return expectAffected(result)
+}
+
+// Windows returns the windows of a coach which end after from, ordered by
+// their start time. If coachID is 0, the windows of all coaches are returned
+func (m *CoachModel) Windows(coachID int, from time.Time) ([]*models.CoachWindow, error) {
+	stmt := `SELECT w.id, w.coach_id, u.name, w.start_time, w.end_time, w.created
+	    FROM coach_windows w INNER JOIN users u ON w.coach_id = u.id
+	    WHERE (? = 0 OR w.coach_id = ?) AND w.end_time > ?
+	    ORDER BY w.start_time, u.name`
+	rows, err := m.DB.Query(stmt, coachID, coachID, from.UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	windows := []*models.CoachWindow{}
+	for rows.Next() {
+		w := &models.CoachWindow{}
+		err = rows.Scan(&w.ID, &w.CoachID, &w.CoachName, &w.Start, &w.End, &w.Created)
+		if err != nil {
+			return nil, err
+		}
+		windows = append(windows, w)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return windows, nil
+}
+
+// Lessons returns the booked lessons of a coach which end after from, ordered
+// by their start time
+func (m *CoachModel) Lessons(coachID int, from time.Time) ([]*models.Session, error) {
+	stmt := `SELECT ` + sessionColumns + ` FROM ` + sessionTables + `
+	    WHERE s.coach_id = ? AND s.status = 'booked' AND s.end_time > ?
+	    ORDER BY s.start_time`
+	return querySessions(m.DB, stmt, coachID, from.UTC())
+}
+
+// BookLesson books the lesson s with its coach, it reserves both the coach
+// and the court during the time slot of the lesson and returns the id of the
+// new session. The slot has to lie inside one of the windows of the coach
+// and the coach must not give another lesson at the same time, otherwise
+// models.ErrCoachUnavailable is returned. If the court is already booked,
+// models.ErrSlotTaken is returned, if it is closed models.ErrCourtClosed. The
+// check is run last, if it is not nil. If the coach does not exist,
+// models.ErrNoRecord is returned
+func (m *CoachModel) BookLesson(s *models.Session, check *models.BookingCheck) (int, error) {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	// The coach is locked before the court, every transaction booking a
+	// lesson takes the locks in this order, so two of them cannot deadlock
+	// and the lessons of a coach are serialized like the bookings of a court
+	if err = lockCoach(tx, s.CoachID, s.Start, s.End, 0); err != nil {
+		return 0, err
+	}
+	if err = lockCourt(tx, s.CourtID); err != nil {
+		return 0, err
+	}
+	if err = courtClosed(tx, s.CourtID, models.Slot{Start: s.Start, End: s.End}); err != nil {
+		return 0, err
+	}
+	if err = courtOffGrid(tx, s.CourtID, models.Slot{Start: s.Start, End: s.End}); err != nil {
+		return 0, err
+	}
+	taken, err := slotTaken(tx, s.CourtID, s.Start, s.End, 0)
+	if err != nil {
+		return 0, err
+	}
+	if taken {
+		return 0, models.ErrSlotTaken
+	}
+	if err = checkBooking(tx, check); err != nil {
+		return 0, err
+	}
+	id, err := insertSession(tx, s)
+	if err != nil {
+		return 0, err
+	}
+	if err = tx.Commit(); err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+// lockCoach locks the row of a coach for the rest of the transaction tx and
+// checks that the coach can give a lesson between start and end: the slot has
+// to lie inside one of the windows of the coach, and the coach must not give
+// another lesson at the same time, otherwise models.ErrCoachUnavailable is
+// returned. The lesson with the id excludeID is not taken into account (use 0
+// to check against all lessons). If the coach does not exist,
+// models.ErrNoRecord is returned
+func lockCoach(tx *sql.Tx, coachID int, start, end time.Time, excludeID int) error {
+	var id int
+	err := tx.QueryRow(`SELECT id FROM users WHERE id = ? AND role = ? FOR UPDATE`, coachID, models.RoleCoach).Scan(&id)
+	if err == sql.ErrNoRows {
+		return models.ErrNoRecord
+	} else if err != nil {
+		return err
+	}
+	var n int
+	stmt := `SELECT COUNT(*) FROM coach_windows
+	    WHERE coach_id = ? AND start_time <= ? AND end_time >= ?`
+	if err = tx.QueryRow(stmt, coachID, start.UTC(), end.UTC()).Scan(&n); err != nil {
+		return err
+	}
+	if n == 0 {
+		return models.ErrCoachUnavailable
+	}
+	stmt = `SELECT COUNT(*) FROM sessions
+	    WHERE coach_id = ? AND id <> ? AND status = 'booked'
+	    AND start_time < ? AND end_time > ?
+	    FOR UPDATE`
+	if err = tx.QueryRow(stmt, coachID, excludeID, end.UTC(), start.UTC()).Scan(&n); err != nil {
+		return err
+	}
+	if n > 0 {
+		return models.ErrCoachUnavailable
+	}
+	return nil
+}
@@ -0,0 +1,158 @@
+package mysql
+
+import (
+	"database/sql"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/erodrigufer/GoTennis/pkg/models"
+)
+
+// Define a CourtModel type which wraps a sql.DB connection pool
+type CourtModel struct {
+	DB *sql.DB
+}
+
+// Insert a new court into the db, it returns the id of the newly inserted
+// court
+func (m *CourtModel) Insert(name, surface string) (int, error) {
+	stmt := `INSERT INTO courts (name, surface, created)
+	VALUES(?, ?, UTC_TIMESTAMP())`
+	result, err := m.DB.Exec(stmt, name, surface)
+	if err != nil {
+		return 0, err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	return int(id), nil
+}
+
+// Columns selected for every court
+const courtColumns = `id, name, surface, outdoor, slot_minutes, durations, created`
+
+// scanCourt copies the courtColumns of a row into a new Court struct
+func scanCourt(row scanner) (*models.Court, error) {
+	c := &models.Court{}
+	var slotMinutes int
+	var durations string
+	err := row.Scan(&c.ID, &c.Name, &c.Surface, &c.Outdoor, &slotMinutes, &durations, &c.Created)
+	if err != nil {
+		return nil, err
+	}
+	c.SlotLength = time.Duration(slotMinutes) * time.Minute
+	// the durations are stored as comma separated minutes
+	c.Durations = []time.Duration{}
+	for _, d := range strings.Split(durations, ",") {
+		minutes, err := strconv.Atoi(strings.TrimSpace(d))
+		if err != nil {
+			continue
+		}
+		c.Durations = append(c.Durations, time.Duration(minutes)*time.Minute)
+	}
+	return c, nil
+}
+
+// Get a court from the db, using its id
+func (m *CourtModel) Get(id int) (*models.Court, error) {
+	stmt := `SELECT ` + courtColumns + ` FROM courts WHERE id = ?`
+	c, err := scanCourt(m.DB.QueryRow(stmt, id))
+	if err == sql.ErrNoRows {
+		return nil, models.ErrNoRecord
+	} else if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Return all the courts of the club, ordered by their name
+func (m *CourtModel) All() ([]*models.Court, error) {
+	stmt := `SELECT ` + courtColumns + ` FROM courts ORDER BY name`
+	rows, err := m.DB.Query(stmt)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	courts := []*models.Court{}
+	for rows.Next() {
+		c, err := scanCourt(rows)
+		if err != nil {
+			return nil, err
+		}
+		courts = append(courts, c)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return courts, nil
+}
+
+// Update the name and surface of an existing court. If the court does not
+// exist, models.ErrNoRecord is returned
+func (m *CourtModel) Update(id int, name, surface string) error {
+	stmt := `UPDATE courts SET name = ?, surface = ? WHERE id = ?`
+	result, err := m.DB.Exec(stmt, name, surface, id)
+	if err != nil {
+		return err
+	}
+	return expectAffected(result)
+}
+
+// courtOffGrid returns models.ErrOffGrid if the slot does not start on the
+// booking grid of the court, or if its duration is not allowed on the court,
+// as part of the transaction tx. The court has to be locked already, so that
+// its grid cannot change until the transaction ends. The grid is taken in the
+// time zone of the slot
+func courtOffGrid(tx *sql.Tx, courtID int, slot models.Slot) error {
+	stmt := `SELECT ` + courtColumns + ` FROM courts WHERE id = ? FOR UPDATE`
+	c, err := scanCourt(tx.QueryRow(stmt, courtID))
+	if err == sql.ErrNoRows {
+		return models.ErrNoRecord
+	} else if err != nil {
+		return err
+	}
+	if !c.OnGrid(slot.Start) || !c.AllowedDuration(slot.End.Sub(slot.Start)) {
+		return models.ErrOffGrid
+	}
+	return nil
+}
+
+// SetGrid changes the booking grid of a court: the step between the start
+// times of the sessions and their allowed durations
+func (m *CourtModel) SetGrid(id int, slotLength time.Duration, durations []time.Duration) error {
+	minutes := make([]string, 0, len(durations))
+	for _, d := range durations {
+		minutes = append(minutes, strconv.Itoa(int(d/time.Minute)))
+	}
+	stmt := `UPDATE courts SET slot_minutes = ?, durations = ? WHERE id = ?`
+	_, err := m.DB.Exec(stmt, int(slotLength/time.Minute), strings.Join(minutes, ","), id)
+	return err
+}
+
+// Delete a court from the db. A court which still has sessions booked on it
+// cannot be deleted, since the foreign key on the sessions table forbids it
+func (m *CourtModel) Delete(id int) error {
+	result, err := m.DB.Exec(`DELETE FROM courts WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+	return expectAffected(result)
+}
+
+// expectAffected returns models.ErrNoRecord if a statement did not affect any
+// row in the db, e.g. because no row matched the given id. The web server
+// always opens the connection with clientFoundRows=true, otherwise an UPDATE
+// which does not change any value would not count the row it matched
+func expectAffected(result sql.Result) error {
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return models.ErrNoRecord
+	}
+	return nil
+}
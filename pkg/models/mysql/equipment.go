@@ -0,0 +1,157 @@
+package mysql
+
+import (
+	"database/sql"
+	"sort"
+
+	"github.com/erodrigufer/GoTennis/pkg/models"
+)
+
+// Define an EquipmentModel type which wraps a sql.DB connection pool, it
+// handles the equipment items and their rentals
+type EquipmentModel struct {
+	DB *sql.DB
+}
+
+// All returns all the equipment items ordered by name
+func (m *EquipmentModel) All() ([]*models.Equipment, error) {
+	rows, err := m.DB.Query(`SELECT id, name, quantity FROM equipment ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []*models.Equipment{}
+	for rows.Next() {
+		e := &models.Equipment{}
+		if err = rows.Scan(&e.ID, &e.Name, &e.Quantity); err != nil {
+			return nil, err
+		}
+		items = append(items, e)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// Rented returns how many units of every equipment item are rented by the
+// booked sessions which overlap with the slot, by equipment id. It is used to
+// tell the user why a rental failed, it does not lock anything
+func (m *EquipmentModel) Rented(slot models.Slot) (map[int]int, error) {
+	stmt := `SELECT r.equipment_id, SUM(r.quantity)
+	    FROM rentals r INNER JOIN sessions s ON r.session_id = s.id
+	    WHERE s.status = 'booked' AND s.start_time < ? AND s.end_time > ?
+	    GROUP BY r.equipment_id`
+	rows, err := m.DB.Query(stmt, slot.End.UTC(), slot.Start.UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rented := map[int]int{}
+	for rows.Next() {
+		var id, n int
+		if err = rows.Scan(&id, &n); err != nil {
+			return nil, err
+		}
+		rented[id] = n
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return rented, nil
+}
+
+// ForSession returns the equipment rented for a session, ordered by name
+func (m *EquipmentModel) ForSession(sessionID int) ([]*models.Rental, error) {
+	stmt := `SELECT r.session_id, r.equipment_id, e.name, r.quantity
+	    FROM rentals r INNER JOIN equipment e ON r.equipment_id = e.id
+	    WHERE r.session_id = ? ORDER BY e.name`
+	rows, err := m.DB.Query(stmt, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rentals := []*models.Rental{}
+	for rows.Next() {
+		r := &models.Rental{}
+		if err = rows.Scan(&r.SessionID, &r.EquipmentID, &r.EquipmentName, &r.Quantity); err != nil {
+			return nil, err
+		}
+		rentals = append(rentals, r)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return rentals, nil
+}
+
+// rentEquipment rents the equipment for the session with the id sessionID,
+// which takes place during the slot, as part of the transaction tx. If an
+// item does not have enough free units during the slot,
+// models.ErrEquipmentUnavailable is returned
+func rentEquipment(tx *sql.Tx, sessionID int, slot models.Slot, rentals []*models.Rental) error {
+	// The rows of the items are locked in the order of their ids, so that
+	// two transactions renting the same items cannot deadlock
+	sorted := make([]*models.Rental, len(rentals))
+	copy(sorted, rentals)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].EquipmentID < sorted[j].EquipmentID })
+
+	for _, r := range sorted {
+		var quantity int
+		err := tx.QueryRow(`SELECT quantity FROM equipment WHERE id = ? FOR UPDATE`, r.EquipmentID).Scan(&quantity)
+		if err == sql.ErrNoRows {
+			return models.ErrNoRecord
+		} else if err != nil {
+			return err
+		}
+		stmt := `SELECT IFNULL(SUM(r.quantity), 0)
+		    FROM rentals r INNER JOIN sessions s ON r.session_id = s.id
+		    WHERE r.equipment_id = ? AND s.status = 'booked'
+		    AND s.start_time < ? AND s.end_time > ?
+		    FOR UPDATE`
+		var rented int
+		if err = tx.QueryRow(stmt, r.EquipmentID, slot.End.UTC(), slot.Start.UTC()).Scan(&rented); err != nil {
+			return err
+		}
+		if rented+r.Quantity > quantity {
+			return models.ErrEquipmentUnavailable
+		}
+		stmt = `INSERT INTO rentals (session_id, equipment_id, quantity) VALUES(?, ?, ?)`
+		if _, err = tx.Exec(stmt, sessionID, r.EquipmentID, r.Quantity); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// moveRentals rents the equipment of the session with the id sessionID again
+// for its new slot, as part of the transaction tx. If an item does not have
+// enough free units during the new slot, models.ErrEquipmentUnavailable is
+// returned
+func moveRentals(tx *sql.Tx, sessionID int, slot models.Slot) error {
+	rows, err := tx.Query(`SELECT equipment_id, quantity FROM rentals WHERE session_id = ?`, sessionID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	rentals := []*models.Rental{}
+	for rows.Next() {
+		r := &models.Rental{SessionID: sessionID}
+		if err = rows.Scan(&r.EquipmentID, &r.Quantity); err != nil {
+			return err
+		}
+		rentals = append(rentals, r)
+	}
+	if err = rows.Err(); err != nil {
+		return err
+	}
+	// the units rented for the session are not counted against the new slot
+	if _, err = tx.Exec(`DELETE FROM rentals WHERE session_id = ?`, sessionID); err != nil {
+		return err
+	}
+	return rentEquipment(tx, sessionID, slot, rentals)
+}
@@ -0,0 +1,176 @@
+package mysql
+
+import (
+	"database/sql"
+	"strings"
+
+	"github.com/erodrigufer/GoTennis/pkg/models"
+	"github.com/erodrigufer/GoTennis/pkg/tennis"
+
+	"github.com/go-sql-driver/mysql" // mysql driver
+)
+
+// Define a LadderModel type which wraps a sql.DB connection pool
+type LadderModel struct {
+	DB *sql.DB
+}
+
+// Join adds a user to the ladder with the initial rating. If the user is
+// already on the ladder, models.ErrAlreadyOnLadder is returned
+func (m *LadderModel) Join(userID int) error {
+	stmt := `INSERT INTO ratings (user_id, rating, joined) VALUES(?, ?, UTC_TIMESTAMP())`
+	_, err := m.DB.Exec(stmt, userID, tennis.InitialRating)
+	if err != nil {
+		// 1062 is the error code for duplicate entry, the user_id is the
+		// primary key of the table
+		if mysqlErr, ok := err.(*mysql.MySQLError); ok {
+			if mysqlErr.Number == 1062 && strings.Contains(mysqlErr.Message, "PRIMARY") {
+				return models.ErrAlreadyOnLadder
+			}
+		}
+	}
+	return err
+}
+
+// Standings returns all the players of the ladder ordered by their rung. The
+// players with the highest rating are at the top, on a tie the player who
+// joined first is higher
+func (m *LadderModel) Standings() ([]*models.Rating, error) {
+	stmt := `SELECT r.user_id, u.name, r.rating, r.matches, r.wins, r.joined
+	    FROM ratings r INNER JOIN users u ON r.user_id = u.id
+	    ORDER BY r.rating DESC, r.joined, r.user_id`
+	rows, err := m.DB.Query(stmt)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	standings := []*models.Rating{}
+	for rows.Next() {
+		r := &models.Rating{Rank: len(standings) + 1}
+		err = rows.Scan(&r.UserID, &r.UserName, &r.Rating, &r.Matches, &r.Wins, &r.Joined)
+		if err != nil {
+			return nil, err
+		}
+		standings = append(standings, r)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return standings, nil
+}
+
+// Get returns the standing of a player on the ladder. The rung depends on
+// all other players, so it is looked up in the standings. If the user is not
+// on the ladder, models.ErrNoRecord is returned
+func (m *LadderModel) Get(userID int) (*models.Rating, error) {
+	standings, err := m.Standings()
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range standings {
+		if r.UserID == userID {
+			return r, nil
+		}
+	}
+	return nil, models.ErrNoRecord
+}
+
+// History returns how the rated matches changed the rating of a player, the
+// latest match first
+func (m *LadderModel) History(userID int) ([]*models.RatingChange, error) {
+	stmt := `SELECT h.user_id, h.session_id, h.opponent_id, u.name, h.won,
+	    h.rating_before, h.rating_after, h.created
+	    FROM rating_history h INNER JOIN users u ON h.opponent_id = u.id
+	    WHERE h.user_id = ? ORDER BY h.created DESC, h.id DESC`
+	rows, err := m.DB.Query(stmt, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	history := []*models.RatingChange{}
+	for rows.Next() {
+		c := &models.RatingChange{}
+		err = rows.Scan(&c.UserID, &c.SessionID, &c.OpponentID, &c.OpponentName, &c.Won,
+			&c.Before, &c.After, &c.Created)
+		if err != nil {
+			return nil, err
+		}
+		history = append(history, c)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return history, nil
+}
+
+// recordMatch updates the ratings of the winner and the loser of the singles
+// session with the Elo rating system as part of the transaction tx, k is the
+// largest change of a rating. The match settles the oldest open challenge
+// between both players. If any of the players is not on the ladder, nothing
+// is changed and models.ErrNoRecord is returned
+func recordMatch(tx *sql.Tx, sessionID, winnerID, loserID int, k float64) error {
+	// Lock the ratings of both players, so that concurrent matches of the
+	// same player are applied one after the other
+	rows, err := tx.Query(`SELECT user_id, rating FROM ratings WHERE user_id IN (?, ?) FOR UPDATE`,
+		winnerID, loserID)
+	if err != nil {
+		return err
+	}
+	ratings := map[int]int{}
+	for rows.Next() {
+		var id, rating int
+		if err = rows.Scan(&id, &rating); err != nil {
+			rows.Close()
+			return err
+		}
+		ratings[id] = rating
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return err
+	}
+	if len(ratings) != 2 {
+		return models.ErrNoRecord
+	}
+
+	winner, loser := tennis.Elo(ratings[winnerID], ratings[loserID], k)
+	update := `UPDATE ratings SET rating = ?, matches = matches + 1, wins = wins + ? WHERE user_id = ?`
+	history := `INSERT INTO rating_history (user_id, session_id, opponent_id, won, rating_before, rating_after, created)
+	VALUES(?, ?, ?, ?, ?, ?, UTC_TIMESTAMP())`
+	if _, err = tx.Exec(update, winner, 1, winnerID); err != nil {
+		return err
+	}
+	if _, err = tx.Exec(update, loser, 0, loserID); err != nil {
+		return err
+	}
+	if _, err = tx.Exec(history, winnerID, sessionID, loserID, true, ratings[winnerID], winner); err != nil {
+		return err
+	}
+	if _, err = tx.Exec(history, loserID, sessionID, winnerID, false, ratings[loserID], loser); err != nil {
+		return err
+	}
+	stmt := `UPDATE challenges SET session_id = ?
+	    WHERE session_id IS NULL AND ((challenger_id = ? AND challenged_id = ?)
+	    OR (challenger_id = ? AND challenged_id = ?))
+	    ORDER BY created, id LIMIT 1`
+	_, err = tx.Exec(stmt, sessionID, winnerID, loserID, loserID, winnerID)
+	return err
+}
+
+// Challenge records that a player challenged another player of the ladder,
+// the rules about who can be challenged are checked beforehand
+func (m *LadderModel) Challenge(challengerID, challengedID int) (int, error) {
+	stmt := `INSERT INTO challenges (challenger_id, challenged_id, created)
+	VALUES(?, ?, UTC_TIMESTAMP())`
+	result, err := m.DB.Exec(stmt, challengerID, challengedID)
+	if err != nil {
+		return 0, err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	return int(id), nil
+}
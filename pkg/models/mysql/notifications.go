@@ -0,0 +1,72 @@
+package mysql
+
+import (
+	"database/sql"
+
+	"github.com/erodrigufer/GoTennis/pkg/models"
+)
+
+// Define a NotificationModel type which wraps a sql.DB connection pool
+type NotificationModel struct {
+	DB *sql.DB
+}
+
+// Insert a new notification for a user, link is an optional URL with more
+// details
+func (m *NotificationModel) Insert(userID int, message, link string) (int, error) {
+	stmt := `INSERT INTO notifications (user_id, message, link, created)
+	VALUES(?, ?, ?, UTC_TIMESTAMP())`
+	result, err := m.DB.Exec(stmt, userID, message, link)
+	if err != nil {
+		return 0, err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	return int(id), nil
+}
+
+// Unread returns the unread notifications of a user, oldest first. They stay
+// unread until MarkRead is called
+func (m *NotificationModel) Unread(userID int) ([]*models.Notification, error) {
+	stmt := `SELECT id, user_id, message, link, created FROM notifications
+	    WHERE user_id = ? AND read_at IS NULL ORDER BY created, id`
+	rows, err := m.DB.Query(stmt, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	notifications := []*models.Notification{}
+	for rows.Next() {
+		n := &models.Notification{}
+		err = rows.Scan(&n.ID, &n.UserID, &n.Message, &n.Link, &n.Created)
+		if err != nil {
+			return nil, err
+		}
+		notifications = append(notifications, n)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return notifications, nil
+}
+
+// MarkRead marks the given notifications of a user as read. A notification
+// inserted after they were fetched stays unread and is displayed the next time
+func (m *NotificationModel) MarkRead(userID int, notifications []*models.Notification) error {
+	if len(notifications) == 0 {
+		return nil
+	}
+	last := 0
+	for _, n := range notifications {
+		if n.ID > last {
+			last = n.ID
+		}
+	}
+	stmt := `UPDATE notifications SET read_at = UTC_TIMESTAMP()
+	    WHERE user_id = ? AND read_at IS NULL AND id <= ?`
+	_, err := m.DB.Exec(stmt, userID, last)
+	return err
+}
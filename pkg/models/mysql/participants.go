@@ -0,0 +1,119 @@
+package mysql
+
+import (
+	"database/sql"
+	"strings"
+
+	"github.com/erodrigufer/GoTennis/pkg/models"
+
+	"github.com/go-sql-driver/mysql" // mysql driver
+)
+
+// Define a ParticipantModel type which wraps a sql.DB connection pool
+type ParticipantModel struct {
+	DB *sql.DB
+}
+
+// Invite a user to play in a session. The invitation is refused with
+// models.ErrSessionFull if the players already invited (and not declined)
+// together with the user who booked the session fill it, and with
+// models.ErrAlreadyInvited if the user was already invited to the session
+func (m *ParticipantModel) Invite(sessionID, userID int) error {
+	return m.add(sessionID, userID, models.ParticipantInvited)
+}
+
+// Join adds a user as an accepted participant of an open session, with the
+// same errors as Invite
+func (m *ParticipantModel) Join(sessionID, userID int) error {
+	return m.add(sessionID, userID, models.ParticipantAccepted)
+}
+
+// add a participant with the given status to a session, if there is still
+// place for another player
+func (m *ParticipantModel) add(sessionID, userID int, status string) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Lock the session's row, so that concurrent invitations cannot both find
+	// a free place in the session
+	s := &models.Session{}
+	err = tx.QueryRow(`SELECT kind FROM sessions WHERE id = ? FOR UPDATE`, sessionID).Scan(&s.Kind)
+	if err == sql.ErrNoRows {
+		return models.ErrNoRecord
+	} else if err != nil {
+		return err
+	}
+	var n int
+	stmt := `SELECT COUNT(*) FROM participants WHERE session_id = ? AND status <> 'declined'`
+	if err = tx.QueryRow(stmt, sessionID).Scan(&n); err != nil {
+		return err
+	}
+	// the user who booked the session is also a player
+	if n+1 >= s.MaxPlayers() {
+		return models.ErrSessionFull
+	}
+
+	// a user joining a session responds at the same time
+	stmt = `INSERT INTO participants (session_id, user_id, status, invited, responded)
+	VALUES(?, ?, ?, UTC_TIMESTAMP(), IF(? = 'invited', NULL, UTC_TIMESTAMP()))`
+	if _, err = tx.Exec(stmt, sessionID, userID, status, status); err != nil {
+		// 1062 is the error code for duplicate entry
+		if mysqlErr, ok := err.(*mysql.MySQLError); ok {
+			if mysqlErr.Number == 1062 && strings.Contains(mysqlErr.Message, "participants_uc_session_user") {
+				return models.ErrAlreadyInvited
+			}
+		}
+		return err
+	}
+	return tx.Commit()
+}
+
+// Respond to a pending invitation to a session, status is either
+// models.ParticipantAccepted or models.ParticipantDeclined. Only invitations
+// to booked sessions which have not started yet can be answered. If the user
+// has no such pending invitation, models.ErrNoRecord is returned
+func (m *ParticipantModel) Respond(sessionID, userID int, status string) error {
+	stmt := `UPDATE participants p INNER JOIN sessions s ON p.session_id = s.id
+	    SET p.status = ?, p.responded = UTC_TIMESTAMP()
+	    WHERE p.session_id = ? AND p.user_id = ? AND p.status = 'invited'
+	    AND s.status = 'booked' AND s.start_time > UTC_TIMESTAMP()`
+	result, err := m.DB.Exec(stmt, status, sessionID, userID)
+	if err != nil {
+		return err
+	}
+	return expectAffected(result)
+}
+
+// ForSession returns the participants invited to a session, in the order in
+// which they were invited
+func (m *ParticipantModel) ForSession(sessionID int) ([]*models.Participant, error) {
+	stmt := `SELECT p.session_id, p.user_id, u.name, p.status, p.invited, p.responded
+	    FROM participants p INNER JOIN users u ON p.user_id = u.id
+	    WHERE p.session_id = ? ORDER BY p.invited, p.id`
+	rows, err := m.DB.Query(stmt, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	participants := []*models.Participant{}
+	for rows.Next() {
+		p := &models.Participant{}
+		var responded sql.NullTime
+		err = rows.Scan(&p.SessionID, &p.UserID, &p.UserName, &p.Status, &p.Invited, &responded)
+		if err != nil {
+			return nil, err
+		}
+		if responded.Valid {
+			p.Responded = responded.Time
+		}
+		participants = append(participants, p)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return participants, nil
+}
@@ -0,0 +1,45 @@
+package mysql
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/erodrigufer/GoTennis/pkg/models"
+)
+
+// Define a PriceModel type which wraps a sql.DB connection pool, it handles
+// the price rules of the courts
+type PriceModel struct {
+	DB *sql.DB
+}
+
+// Pricing returns the price rules of all courts, ordered by court, weekday
+// and start of the time band
+func (m *PriceModel) Pricing() (*models.Pricing, error) {
+	// the TIME columns are fetched as seconds since midnight
+	stmt := `SELECT id, IFNULL(court_id, 0), weekday, TIME_TO_SEC(starts), TIME_TO_SEC(ends),
+	    band, member_rate, guest_rate
+	    FROM price_rules ORDER BY court_id, weekday, starts`
+	rows, err := m.DB.Query(stmt)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rules := []*models.PriceRule{}
+	for rows.Next() {
+		r := &models.PriceRule{}
+		var starts, ends int
+		err = rows.Scan(&r.ID, &r.CourtID, &r.Weekday, &starts, &ends, &r.Band, &r.MemberRate, &r.GuestRate)
+		if err != nil {
+			return nil, err
+		}
+		r.Starts = time.Duration(starts) * time.Second
+		r.Ends = time.Duration(ends) * time.Second
+		rules = append(rules, r)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return &models.Pricing{Rules: rules}, nil
+}
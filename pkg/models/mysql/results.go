@@ -0,0 +1,132 @@
+package mysql
+
+import (
+	"database/sql"
+	"strings"
+
+	"github.com/erodrigufer/GoTennis/pkg/models"
+	"github.com/erodrigufer/GoTennis/pkg/tennis"
+
+	"github.com/go-sql-driver/mysql" // mysql driver
+)
+
+// Define a ResultModel type which wraps a sql.DB connection pool
+type ResultModel struct {
+	DB *sql.DB
+}
+
+// Insert the pending result of a session reported by a user, the score has
+// to be validated beforehand. If the session already has a result,
+// models.ErrResultExists is returned
+func (m *ResultModel) Insert(r *models.MatchResult) (int, error) {
+	stmt := `INSERT INTO results (session_id, score, best_of, final_set, winner, status, reported_by, created)
+	VALUES(?, ?, ?, ?, ?, 'pending', ?, UTC_TIMESTAMP())`
+	result, err := m.DB.Exec(stmt, r.SessionID, r.Score, r.BestOf, r.FinalSet, r.Winner, r.ReportedBy)
+	if err != nil {
+		// 1062 is the error code for duplicate entry
+		if mysqlErr, ok := err.(*mysql.MySQLError); ok {
+			if mysqlErr.Number == 1062 && strings.Contains(mysqlErr.Message, "results_uc_session") {
+				return 0, models.ErrResultExists
+			}
+		}
+		return 0, err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	return int(id), nil
+}
+
+// Confirm the pending result of a session, userID is the user who confirmed
+// it. The result takes effect in the same transaction: if opponentID is not
+// 0, the session was a singles match against that player and the ratings of
+// both players are updated if they are on the ladder, settling the oldest
+// open challenge between them. If the session is a tournament match, its
+// winner advances in the draw. If the session has no pending result,
+// models.ErrNoRecord is returned
+func (m *ResultModel) Confirm(sessionID, userID, opponentID int, k float64) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var ownerID int
+	var winner, score string
+	stmt := `SELECT s.user_id, r.winner, r.score
+	    FROM results r INNER JOIN sessions s ON r.session_id = s.id
+	    WHERE r.session_id = ? AND r.status = 'pending' FOR UPDATE`
+	err = tx.QueryRow(stmt, sessionID).Scan(&ownerID, &winner, &score)
+	if err == sql.ErrNoRows {
+		return models.ErrNoRecord
+	} else if err != nil {
+		return err
+	}
+	stmt = `UPDATE results SET status = 'confirmed', confirmed_by = ?, confirmed = UTC_TIMESTAMP()
+	    WHERE session_id = ?`
+	if _, err = tx.Exec(stmt, userID, sessionID); err != nil {
+		return err
+	}
+
+	// the score is written from the point of view of the user who booked
+	// the session
+	if opponentID != 0 {
+		winnerID, loserID := ownerID, opponentID
+		if winner == tennis.Away {
+			winnerID, loserID = loserID, winnerID
+		}
+		err = recordMatch(tx, sessionID, winnerID, loserID, k)
+		if err != nil && err != models.ErrNoRecord {
+			return err
+		}
+	}
+
+	// the home player of a tournament match books its session
+	var matchID, homeID, awayID int
+	stmt = `SELECT id, IFNULL(home_id, 0), IFNULL(away_id, 0) FROM tournament_matches WHERE session_id = ?`
+	err = tx.QueryRow(stmt, sessionID).Scan(&matchID, &homeID, &awayID)
+	if err == nil {
+		winnerID := homeID
+		if winner == tennis.Away {
+			winnerID = awayID
+		}
+		if err = recordWinner(tx, matchID, winnerID, score); err != nil {
+			return err
+		}
+	} else if err != sql.ErrNoRows {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Reject deletes the pending result of a session, so that it can be reported
+// again. If the session has no pending result, models.ErrNoRecord is
+// returned
+func (m *ResultModel) Reject(sessionID int) error {
+	result, err := m.DB.Exec(`DELETE FROM results WHERE session_id = ? AND status = 'pending'`, sessionID)
+	if err != nil {
+		return err
+	}
+	return expectAffected(result)
+}
+
+// ForSession returns the result of a session, if no result was recorded yet
+// models.ErrNoRecord is returned
+func (m *ResultModel) ForSession(sessionID int) (*models.MatchResult, error) {
+	stmt := `SELECT r.id, r.session_id, r.score, r.best_of, r.final_set, r.winner,
+	    r.status, r.reported_by, u.name, IFNULL(r.confirmed_by, 0), IFNULL(c.name, ''), r.created
+	    FROM results r INNER JOIN users u ON r.reported_by = u.id
+	    LEFT JOIN users c ON r.confirmed_by = c.id
+	    WHERE r.session_id = ?`
+	r := &models.MatchResult{}
+	err := m.DB.QueryRow(stmt, sessionID).Scan(&r.ID, &r.SessionID, &r.Score, &r.BestOf,
+		&r.FinalSet, &r.Winner, &r.Status, &r.ReportedBy, &r.ReportedByName,
+		&r.ConfirmedBy, &r.ConfirmedByName, &r.Created)
+	if err == sql.ErrNoRows {
+		return nil, models.ErrNoRecord
+	} else if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
@@ -0,0 +1,305 @@
+package mysql
+
+import (
+	"database/sql"
+	"sort"
+	"time"
+
+	"github.com/erodrigufer/GoTennis/pkg/models"
+)
+
+// Define a ScheduleModel type which wraps a sql.DB connection pool, it handles
+// the opening hours and the blackout periods of the courts
+type ScheduleModel struct {
+	DB *sql.DB
+}
+
+// Availability returns the opening hours of all courts and the blackouts
+// which overlap with the time range between from and to
+func (m *ScheduleModel) Availability(from, to time.Time) (*models.Availability, error) {
+	hours, err := m.Hours(0)
+	if err != nil {
+		return nil, err
+	}
+	blackouts, err := m.Blackouts(from, to)
+	if err != nil {
+		return nil, err
+	}
+	return &models.Availability{Hours: hours, Blackouts: blackouts}, nil
+}
+
+// Hours returns the opening hours of a court ordered by weekday and opening
+// time. If courtID is 0, the opening hours of all courts are returned
+func (m *ScheduleModel) Hours(courtID int) ([]*models.OpeningHours, error) {
+	// the TIME columns are fetched as seconds since midnight
+	stmt := `SELECT id, court_id, weekday, TIME_TO_SEC(opens), TIME_TO_SEC(closes)
+	    FROM opening_hours WHERE (? = 0 OR court_id = ?)
+	    ORDER BY court_id, weekday, opens`
+	return queryHours(m.DB, stmt, courtID, courtID)
+}
+
+// queryHours runs a query selecting opening hours and returns them
+func queryHours(q queryer, stmt string, args ...interface{}) ([]*models.OpeningHours, error) {
+	rows, err := q.Query(stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	hours := []*models.OpeningHours{}
+	for rows.Next() {
+		h := &models.OpeningHours{}
+		var opens, closes int
+		err = rows.Scan(&h.ID, &h.CourtID, &h.Weekday, &opens, &closes)
+		if err != nil {
+			return nil, err
+		}
+		h.Opens = time.Duration(opens) * time.Second
+		h.Closes = time.Duration(closes) * time.Second
+		hours = append(hours, h)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return hours, nil
+}
+
+// InsertHours adds an opening window to a court on a given weekday, opens and
+// closes are the offsets from midnight
+func (m *ScheduleModel) InsertHours(courtID int, weekday time.Weekday, opens, closes time.Duration) (int, error) {
+	stmt := `INSERT INTO opening_hours (court_id, weekday, opens, closes)
+	VALUES(?, ?, SEC_TO_TIME(?), SEC_TO_TIME(?))`
+	result, err := m.DB.Exec(stmt, courtID, int(weekday), int(opens.Seconds()), int(closes.Seconds()))
+	if err != nil {
+		return 0, err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	return int(id), nil
+}
+
+// DeleteHours removes an opening window
+func (m *ScheduleModel) DeleteHours(id int) error {
+	result, err := m.DB.Exec(`DELETE FROM opening_hours WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+	return expectAffected(result)
+}
+
+// Blackouts returns the blackouts which overlap with the time range between
+// from and to, ordered by their start time
+func (m *ScheduleModel) Blackouts(from, to time.Time) ([]*models.Blackout, error) {
+	stmt := `SELECT b.id, IFNULL(b.court_id, 0), IFNULL(c.name, ''), b.start_time,
+	    b.end_time, b.reason, b.created
+	    FROM blackouts b LEFT JOIN courts c ON b.court_id = c.id
+	    WHERE b.start_time < ? AND b.end_time > ?
+	    ORDER BY b.start_time`
+	return queryBlackouts(m.DB, stmt, to.UTC(), from.UTC())
+}
+
+// queryBlackouts runs a query selecting blackouts and returns them
+func queryBlackouts(q queryer, stmt string, args ...interface{}) ([]*models.Blackout, error) {
+	rows, err := q.Query(stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	blackouts := []*models.Blackout{}
+	for rows.Next() {
+		b := &models.Blackout{}
+		err = rows.Scan(&b.ID, &b.CourtID, &b.CourtName, &b.Start, &b.End, &b.Reason, &b.Created)
+		if err != nil {
+			return nil, err
+		}
+		blackouts = append(blackouts, b)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return blackouts, nil
+}
+
+// InsertBlackout closes a court between start and end, a courtID of 0 closes
+// all the courts of the club. The closed courts are locked like for a
+// booking, so that no session can be booked on them while the blackout is
+// inserted
+func (m *ScheduleModel) InsertBlackout(courtID int, start, end time.Time, reason string) (int, error) {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	// the courts are locked in the order of their ids, like in RainOut
+	ids := []int{courtID}
+	if courtID == 0 {
+		if ids, err = allCourtIDs(tx); err != nil {
+			return 0, err
+		}
+	}
+	for _, id := range ids {
+		if err = lockCourt(tx, id); err != nil {
+			return 0, err
+		}
+	}
+	stmt := `INSERT INTO blackouts (court_id, start_time, end_time, reason, created)
+	VALUES(?, ?, ?, ?, UTC_TIMESTAMP())`
+	result, err := tx.Exec(stmt, nullInt(courtID), start.UTC(), end.UTC(), reason)
+	if err != nil {
+		return 0, err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	if err = tx.Commit(); err != nil {
+		return 0, err
+	}
+	return int(id), nil
+}
+
+// allCourtIDs returns the ids of all the courts in ascending order, as part
+// of the transaction tx
+func allCourtIDs(tx *sql.Tx) ([]int, error) {
+	rows, err := tx.Query(`SELECT id FROM courts ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []int{}
+	for rows.Next() {
+		var id int
+		if err = rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// courtClosed returns models.ErrCourtClosed if the court is closed during
+// the slot, as part of the transaction tx. The court has to be locked
+// already: blackouts are only inserted while holding the lock of the courts
+// they close, so none can be added until the transaction ends. The opening
+// hours are taken in the time zone of the slot
+func courtClosed(tx *sql.Tx, courtID int, slot models.Slot) error {
+	stmt := `SELECT id, court_id, weekday, TIME_TO_SEC(opens), TIME_TO_SEC(closes)
+	    FROM opening_hours WHERE court_id = ?
+	    LOCK IN SHARE MODE`
+	hours, err := queryHours(tx, stmt, courtID)
+	if err != nil {
+		return err
+	}
+	stmt = `SELECT b.id, IFNULL(b.court_id, 0), IFNULL(c.name, ''), b.start_time,
+	    b.end_time, b.reason, b.created
+	    FROM blackouts b LEFT JOIN courts c ON b.court_id = c.id
+	    WHERE (b.court_id IS NULL OR b.court_id = ?) AND b.start_time < ? AND b.end_time > ?
+	    LOCK IN SHARE MODE`
+	blackouts, err := queryBlackouts(tx, stmt, courtID, slot.End.UTC(), slot.Start.UTC())
+	if err != nil {
+		return err
+	}
+	a := &models.Availability{Hours: hours, Blackouts: blackouts}
+	if a.Closed(courtID, slot) != "" {
+		return models.ErrCourtClosed
+	}
+	return nil
+}
+
+// DeleteBlackout removes a blackout, the court can be booked again during
+// that period
+func (m *ScheduleModel) DeleteBlackout(id int) error {
+	result, err := m.DB.Exec(`DELETE FROM blackouts WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+	return expectAffected(result)
+}
+
+// RainOut closes the courts between start and end and cancels all the booked
+// sessions on them which overlap with that period and have not ended yet, in
+// a single transaction. The reason is stored with the blackouts and the
+// cancelled sessions, which are returned so that their players can be
+// notified. The tournament matches of the cancelled sessions are unscheduled,
+// the ids of their tournaments are returned so that the matches can be
+// scheduled again
+func (m *ScheduleModel) RainOut(courtIDs []int, start, end time.Time, reason string, userID int) ([]*models.Session, []int, error) {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer tx.Rollback()
+
+	// sessions which are already over are left alone, even if the blackout
+	// starts in the past
+	now := time.Now().UTC()
+	cancel := models.Slot{Start: start.UTC(), End: end.UTC()}.From(now)
+	// lock the courts always in the same order, so that two rain-outs
+	// cannot deadlock each other
+	ids := append([]int{}, courtIDs...)
+	sort.Ints(ids)
+	cancelled := []*models.Session{}
+	for _, courtID := range ids {
+		if err = lockCourt(tx, courtID); err != nil {
+			return nil, nil, err
+		}
+		stmt := `INSERT INTO blackouts (court_id, start_time, end_time, reason, created)
+		VALUES(?, ?, ?, ?, UTC_TIMESTAMP())`
+		if _, err = tx.Exec(stmt, courtID, start.UTC(), end.UTC(), reason); err != nil {
+			return nil, nil, err
+		}
+
+		stmt = `SELECT ` + sessionColumns + ` FROM ` + sessionTables + `
+		    WHERE s.court_id = ? AND s.status = 'booked'
+		    AND s.start_time < ? AND s.end_time > ?
+		    ORDER BY s.start_time
+		    FOR UPDATE`
+		sessions, err := querySessions(tx, stmt, courtID, cancel.End, cancel.Start)
+		if err != nil {
+			return nil, nil, err
+		}
+		cancelled = append(cancelled, sessions...)
+	}
+
+	tournaments := []int{}
+	for _, s := range cancelled {
+		stmt := `UPDATE sessions SET status = 'cancelled', cancelled_by = ?,
+		    cancelled_at = ?, cancel_reason = ? WHERE id = ?`
+		if _, err = tx.Exec(stmt, userID, now, reason, s.ID); err != nil {
+			return nil, nil, err
+		}
+		s.Status = models.StatusCancelled
+		s.CancelledBy = userID
+		s.CancelledAt = now
+		s.CancelReason = reason
+
+		// a match without a winner has to be played in another session
+		var tournamentID int
+		stmt = `SELECT tournament_id FROM tournament_matches
+		    WHERE session_id = ? AND winner_id IS NULL FOR UPDATE`
+		err = tx.QueryRow(stmt, s.ID).Scan(&tournamentID)
+		if err == sql.ErrNoRows {
+			continue
+		} else if err != nil {
+			return nil, nil, err
+		}
+		stmt = `UPDATE tournament_matches SET session_id = NULL WHERE session_id = ?`
+		if _, err = tx.Exec(stmt, s.ID); err != nil {
+			return nil, nil, err
+		}
+		tournaments = append(tournaments, tournamentID)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, nil, err
+	}
+	return cancelled, tournaments, nil
+}
@@ -0,0 +1,157 @@
+package mysql
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/erodrigufer/GoTennis/pkg/models"
+)
+
+// Define a SeriesModel type which wraps a sql.DB connection pool
+type SeriesModel struct {
+	DB *sql.DB
+}
+
+// Insert a new series into the db together with one session for every slot.
+// All slots are checked for conflicts inside the same transaction, if any of
+// them overlaps with another session on the court, no session is created and
+// models.ErrSlotTaken is returned, if the court is closed during any of them
+// models.ErrCourtClosed. Every session is charged its own price
+// according to the pricing and rents the equipment of the rentals, if not
+// enough units are free for any of them models.ErrEquipmentUnavailable is
+// returned. The check is run for all the slots at once, if it is not nil. If
+// correct, it returns the id of the series
+func (m *SeriesModel) Insert(s *models.Series, content string, slots []models.Slot, pricing *models.Pricing, rentals []*models.Rental, check *models.BookingCheck) (int, error) {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	if err = lockCourt(tx, s.CourtID); err != nil {
+		return 0, err
+	}
+	for _, slot := range slots {
+		if err = courtClosed(tx, s.CourtID, slot); err != nil {
+			return 0, err
+		}
+		if err = courtOffGrid(tx, s.CourtID, slot); err != nil {
+			return 0, err
+		}
+		taken, err := slotTaken(tx, s.CourtID, slot.Start, slot.End, 0)
+		if err != nil {
+			return 0, err
+		}
+		if taken {
+			return 0, models.ErrSlotTaken
+		}
+	}
+	if err = checkBooking(tx, check); err != nil {
+		return 0, err
+	}
+
+	stmt := `INSERT INTO series (user_id, court_id, kind, guests, title, frequency, until_date, occurrences, created)
+	VALUES(?, ?, ?, ?, ?, ?, ?, ?, UTC_TIMESTAMP())`
+	var until interface{}
+	if !s.Until.IsZero() {
+		until = s.Until
+	}
+	result, err := tx.Exec(stmt, s.UserID, s.CourtID, s.Kind, s.Guests, s.Title, s.Frequency, until, nullInt(s.Count))
+	if err != nil {
+		return 0, err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	for _, slot := range slots {
+		sessionID, err := insertSession(tx, &models.Session{
+			UserID:   s.UserID,
+			CourtID:  s.CourtID,
+			SeriesID: int(id),
+			Kind:     s.Kind,
+			Guests:   s.Guests,
+			Price:    pricing.Price(s.CourtID, slot, s.Guests),
+			Title:    s.Title,
+			Content:  content,
+			Start:    slot.Start,
+			End:      slot.End,
+		})
+		if err != nil {
+			return 0, err
+		}
+		if err = rentEquipment(tx, sessionID, slot, rentals); err != nil {
+			return 0, err
+		}
+	}
+	if err = tx.Commit(); err != nil {
+		return 0, err
+	}
+	return int(id), nil
+}
+
+// Conflicts returns the slots which overlap with any session already booked
+// on the court. It is used to tell the user why a series could not be
+// created, it does not lock anything
+func (m *SeriesModel) Conflicts(courtID int, slots []models.Slot) ([]models.Slot, error) {
+	stmt := `SELECT COUNT(*) FROM sessions
+	    WHERE court_id = ? AND status = 'booked' AND start_time < ? AND end_time > ?`
+	conflicts := []models.Slot{}
+	for _, slot := range slots {
+		var n int
+		err := m.DB.QueryRow(stmt, courtID, slot.End.UTC(), slot.Start.UTC()).Scan(&n)
+		if err != nil {
+			return nil, err
+		}
+		if n > 0 {
+			conflicts = append(conflicts, slot)
+		}
+	}
+	return conflicts, nil
+}
+
+// Get a series from the db, using its id
+func (m *SeriesModel) Get(id int) (*models.Series, error) {
+	stmt := `SELECT r.id, r.user_id, r.court_id, c.name, r.kind, r.guests, r.title, r.frequency,
+	    r.until_date, IFNULL(r.occurrences, 0), r.created
+	    FROM series r INNER JOIN courts c ON r.court_id = c.id
+	    WHERE r.id = ?`
+	s := &models.Series{}
+	var until sql.NullTime
+	err := m.DB.QueryRow(stmt, id).Scan(&s.ID, &s.UserID, &s.CourtID, &s.CourtName,
+		&s.Kind, &s.Guests, &s.Title, &s.Frequency, &until, &s.Count, &s.Created)
+	if err == sql.ErrNoRows {
+		return nil, models.ErrNoRecord
+	} else if err != nil {
+		return nil, err
+	}
+	if until.Valid {
+		s.Until = until.Time
+	}
+	return s, nil
+}
+
+// Sessions returns all the sessions of a series, ordered by their start time
+func (m *SeriesModel) Sessions(id int) ([]*models.Session, error) {
+	stmt := `SELECT ` + sessionColumns + ` FROM ` + sessionTables + `
+	    WHERE s.series_id = ? ORDER BY s.start_time`
+	return querySessions(m.DB, stmt, id)
+}
+
+// Cancel all the booked sessions of a series which start after the time
+// notBefore, recording the user who cancelled them. It returns the number of
+// cancelled sessions, the sessions which already took place are kept
+func (m *SeriesModel) Cancel(id, userID int, notBefore time.Time) (int, error) {
+	stmt := `UPDATE sessions SET status = 'cancelled', cancelled_by = ?,
+	    cancelled_at = UTC_TIMESTAMP()
+	    WHERE series_id = ? AND status = 'booked' AND start_time > ?`
+	result, err := m.DB.Exec(stmt, userID, id, notBefore.UTC())
+	if err != nil {
+		return 0, err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	return int(n), nil
+}
@@ -3,6 +3,7 @@ package mysql
 
 import (
 	"database/sql"
+	"time"
 
 	"github.com/erodrigufer/GoTennis/pkg/models"
 )
@@ -12,25 +13,86 @@ type SessionModel struct {
 	DB *sql.DB
 }
 
-// Insert new session into the db, if correct it returns the id of the newly
-// inserted session into the db
-func (m *SessionModel) Insert(title, content, expires string) (int, error) {
+// Insert new session booked by a user into the db, if correct it returns the
+// id of the newly inserted session into the db. The session of the given kind
+// (singles or doubles) takes place on its court between its start and end,
+// both times are stored as UTC. If the time slot overlaps with another session
+// on the same court, models.ErrSlotTaken is returned, if the court is closed
+// during the slot models.ErrCourtClosed. The equipment of the rentals is
+// rented for the session, if not enough units are free
+// models.ErrEquipmentUnavailable is returned and the session is not booked.
+// The check is run last, if it is not nil (see models.BookingCheck)
+func (m *SessionModel) Insert(s *models.Session, rentals []*models.Rental, check *models.BookingCheck) (int, error) {
+	// The overlap check and the insert are run inside a single transaction,
+	// otherwise two concurrent requests could both find the slot free and
+	// then both insert their session
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	// Rollback is a no-op if the transaction has already been committed, so
+	// it is safe to always defer it
+	defer tx.Rollback()
+
+	// Lock the court's row until the transaction ends, every other
+	// transaction trying to book the same court has to wait here
+	if err = lockCourt(tx, s.CourtID); err != nil {
+		return 0, err
+	}
+	if err = courtClosed(tx, s.CourtID, models.Slot{Start: s.Start, End: s.End}); err != nil {
+		return 0, err
+	}
+	if err = courtOffGrid(tx, s.CourtID, models.Slot{Start: s.Start, End: s.End}); err != nil {
+		return 0, err
+	}
+	taken, err := slotTaken(tx, s.CourtID, s.Start, s.End, 0)
+	if err != nil {
+		return 0, err
+	}
+	if taken {
+		return 0, models.ErrSlotTaken
+	}
+	if err = checkBooking(tx, check); err != nil {
+		return 0, err
+	}
+
+	id, err := insertSession(tx, s)
+	if err != nil {
+		return 0, err
+	}
+	err = rentEquipment(tx, id, models.Slot{Start: s.Start, End: s.End}, rentals)
+	if err != nil {
+		return 0, err
+	}
+	if err = tx.Commit(); err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+// insertSession inserts a new session booked by a user into the db as part of
+// the transaction tx, without checking if the slot is free. A SeriesID of 0
+// stores a session which is not part of any series, a CoachID of 0 a session
+// which is not a lesson
+func insertSession(tx *sql.Tx, s *models.Session) (int, error) {
 	// SQL-command to execute, `` to write command over 2 lines for readability
 	// ? is a placeholder parameter, since we would otherwise be using untrusted
 	// unsanitized user input data
-	stmt := `INSERT INTO sessions (title, content, created, expires)
-	VALUES(?, ?, UTC_TIMESTAMP(), DATE_ADD(UTC_TIMESTAMP(), INTERVAL ? DAY))`
-	// Use the Exec() method on the embedded connection pool to execute the
-	// statement. The first parameter is the SQL statement, followed by the
-	// title, content and expiry values for the placeholder parameters. This
-	// method returns a sql.Result object, which contains some basic
-	// information about what happened when the statement was executed.
-	result, err := m.DB.Exec(stmt, title, content, expires)
+	stmt := `INSERT INTO sessions (user_id, court_id, series_id, coach_id, kind, guests, price,
+	title, content, created, start_time, end_time)
+	VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, UTC_TIMESTAMP(), ?, ?)`
+	// Use the Exec() method on the transaction to execute the statement. The
+	// first parameter is the SQL statement, followed by the values for the
+	// placeholder parameters. This method returns a sql.Result object, which
+	// contains some basic information about what happened when the statement
+	// was executed.
+	result, err := tx.Exec(stmt, s.UserID, s.CourtID, nullInt(s.SeriesID), nullInt(s.CoachID), s.Kind,
+		s.Guests, s.Price, s.Title, s.Content, s.Start.UTC(), s.End.UTC())
 	if err != nil {
 		return 0, err
 	}
 	// Use the LastInsertId() method on the result object to get the ID of our
-	// newly inserted record in the snippets table.
+	// newly inserted record in the sessions table.
 	id, err := result.LastInsertId()
 	if err != nil {
 		return 0, err
@@ -40,27 +102,63 @@ func (m *SessionModel) Insert(title, content, expires string) (int, error) {
 	return int(id), nil
 }
 
+// nullInt maps the id 0 to a NULL value in the db
+func nullInt(id int) interface{} {
+	if id == 0 {
+		return nil
+	}
+	return id
+}
+
+// lockCourt locks the row of a court for the rest of the transaction tx, so
+// that the bookings of a court are serialized. If the court does not exist,
+// models.ErrNoRecord is returned
+func lockCourt(tx *sql.Tx, courtID int) error {
+	var id int
+	err := tx.QueryRow(`SELECT id FROM courts WHERE id = ? FOR UPDATE`, courtID).Scan(&id)
+	if err == sql.ErrNoRows {
+		return models.ErrNoRecord
+	}
+	return err
+}
+
+// slotTaken reports if any session on the court overlaps with the time slot
+// between start and end. The session with the id excludeID is not taken into
+// account (use 0 to check against all sessions)
+func slotTaken(tx *sql.Tx, courtID int, start, end time.Time, excludeID int) (bool, error) {
+	// Two slots overlap if each one starts before the other one ends. The
+	// query is a locking read, so that it always sees the latest committed
+	// sessions and not the snapshot from the beginning of the transaction
+	stmt := `SELECT COUNT(*) FROM sessions
+	    WHERE court_id = ? AND id <> ? AND status = 'booked'
+	    AND start_time < ? AND end_time > ?
+	    FOR UPDATE`
+	var n int
+	err := tx.QueryRow(stmt, courtID, excludeID, end.UTC(), start.UTC()).Scan(&n)
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
 // Get Session from db, using its id
 func (m *SessionModel) Get(id int) (*models.Session, error) {
 	// SQL statement to execute
 	// use placeholder data ? for unsanitized user input
-	// the session should not have expired yet
-	stmt := `SELECT id, title, content, created, expires FROM sessions
-			    WHERE expires > UTC_TIMESTAMP() AND id = ?`
+	// sessions which already took place are also returned, e.g. to record
+	// their result
+	// the name of the court is fetched from the courts table
+	stmt := `SELECT ` + sessionColumns + ` FROM ` + sessionTables + `
+			    WHERE s.id = ?`
 	// Use the QueryRow() method on the connection pool to execute the
 	// SQL statement, passing in the untrusted id variable as the value for the
 	// placeholder parameter. This returns a pointer to a sql.Row object which
 	// holds the result from the database.
 	row := m.DB.QueryRow(stmt, id)
-	// Initialize a pointer to a new zeroed Session struct.
-	s := &models.Session{}
-	// Use row.Scan() to copy the values from each field in sql.Row to the
-	// corresponding field in the Session struct. Notice that the arguments
-	// to row.Scan are *pointers* to the place you want to copy the data into,
-	// and the number of arguments must be exactly the same as the number of
-	// columns returned by your statement. If the query returns no rows, then
-	// row.Scan() will return a sql.ErrNoRows error.
-	err := row.Scan(&s.ID, &s.Title, &s.Content, &s.Created, &s.Expires)
+	// scanSession copies the values from each field in sql.Row to the
+	// corresponding field of a new Session struct. If the query returns no
+	// rows, then row.Scan() will return a sql.ErrNoRows error.
+	s, err := scanSession(row)
 	if err == sql.ErrNoRows {
 		return nil, models.ErrNoRecord
 		// another kind of error happened
@@ -71,22 +169,89 @@ func (m *SessionModel) Get(id int) (*models.Session, error) {
 	return s, nil
 }
 
-// Return the 10 most recently created sessions
-func (m *SessionModel) Latest() ([]*models.Session, error) {
+// Return the 10 most recently created sessions. If courtID is not 0, only the
+// sessions booked on that court are returned
+func (m *SessionModel) Latest(courtID int) ([]*models.Session, error) {
 	// SQL statement to execute
-	// Only get sessions that have not expired, ordered them by creation date
-	// and limit them to 10
-	stmt := `SELECT id, title, content, created, expires FROM sessions
-	    WHERE expires > UTC_TIMESTAMP() ORDER BY created DESC LIMIT 10`
-	// returns sql.Rows with result set for query
-	// this query does not need any more input parameters, since it always looks
-	// for the latest 10 results
-	rows, err := m.DB.Query(stmt)
+	// Only get sessions that have not ended and were not cancelled, ordered
+	// them by creation date
+	// and limit them to 10. A courtID of 0 disables the court filter
+	stmt := `SELECT ` + sessionColumns + ` FROM ` + sessionTables + `
+	    WHERE s.end_time > UTC_TIMESTAMP() AND s.status = 'booked'
+	    AND (? = 0 OR s.court_id = ?)
+	    ORDER BY s.created DESC LIMIT 10`
+	return querySessions(m.DB, stmt, courtID, courtID)
+}
+
+// Return all booked sessions which overlap with the time range between from
+// and to, ordered by their start time. If courtID is not 0, only the sessions
+// booked on that court are returned
+func (m *SessionModel) Between(courtID int, from, to time.Time) ([]*models.Session, error) {
+	stmt := `SELECT ` + sessionColumns + ` FROM ` + sessionTables + `
+	    WHERE s.start_time < ? AND s.end_time > ? AND s.status = 'booked'
+	    AND (? = 0 OR s.court_id = ?)
+	    ORDER BY s.start_time`
+	return querySessions(m.DB, stmt, to.UTC(), from.UTC(), courtID, courtID)
+}
+
+// Columns selected for every session, they have to be selected from the
+// sessionTables
+const sessionColumns = `s.id, s.user_id, ou.name, s.court_id, c.name, IFNULL(s.series_id, 0), s.kind, s.is_open,
+	IFNULL(s.coach_id, 0), IFNULL(co.name, ''), s.guests, s.price, s.title,
+	s.content, s.created, s.start_time, s.end_time, s.status,
+	IFNULL(s.cancelled_by, 0), IFNULL(cu.name, ''), s.cancelled_at, s.cancel_reason,
+	s.checked_in_at, s.no_show, IFNULL(s.no_show_by, 0), IFNULL(s.no_show_cleared_by, 0)`
+
+// Tables joined to select the sessionColumns, the sessions table is aliased
+// as s
+const sessionTables = `sessions s INNER JOIN courts c ON s.court_id = c.id
+	INNER JOIN users ou ON s.user_id = ou.id
+	LEFT JOIN users co ON s.coach_id = co.id
+	LEFT JOIN users cu ON s.cancelled_by = cu.id`
+
+// scanner is implemented by both *sql.Row and *sql.Rows
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// queryer is implemented by both *sql.DB and *sql.Tx
+type queryer interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// scanSession copies the sessionColumns of a row into a new Session struct
+func scanSession(row scanner) (*models.Session, error) {
+	s := &models.Session{}
+	var cancelled, checkedIn sql.NullTime
+	// the arguments to Scan are *pointers* to the place you want to copy the
+	// data into, and the number of arguments must be exactly the same as the
+	// number of columns returned by the statement
+	err := row.Scan(&s.ID, &s.UserID, &s.UserName, &s.CourtID, &s.CourtName, &s.SeriesID, &s.Kind, &s.Open,
+		&s.CoachID, &s.CoachName, &s.Guests, &s.Price, &s.Title,
+		&s.Content, &s.Created, &s.Start, &s.End, &s.Status,
+		&s.CancelledBy, &s.CancelledByName, &cancelled, &s.CancelReason,
+		&checkedIn, &s.NoShow, &s.NoShowBy, &s.NoShowClearedBy)
+	if err != nil {
+		return nil, err
+	}
+	if cancelled.Valid {
+		s.CancelledAt = cancelled.Time
+	}
+	if checkedIn.Valid {
+		s.CheckedIn = checkedIn.Time
+	}
+	return s, nil
+}
+
+// querySessions runs a query selecting the sessionColumns and returns all
+// the sessions of the resultset
+func querySessions(q queryer, stmt string, args ...interface{}) ([]*models.Session, error) {
+	rows, err := q.Query(stmt, args...)
 	if err != nil {
 		return nil, err
 	}
 	// We defer rows.Close() to ensure the sql.Rows resultset is
-	// always properly closed before the Latest() method returns. This defer
+	// always properly closed before the function returns. This defer
 	// statement should come *after* you check for an error from the Query()
 	// method. Otherwise, if Query() returns an error, you'll get a panic
 	// trying to close a nil resultset.
@@ -99,18 +264,10 @@ func (m *SessionModel) Latest() ([]*models.Session, error) {
 	// resultset automatically closes itself and frees-up the underlying
 	// database connection.
 	for rows.Next() {
-		// Create a pointer to a new zeroed Session struct.
-		s := &models.Session{}
-		// Use rows.Scan() to copy the values from each field in the row to the
-		// new Session object that we created. Again, the arguments to row.Scan
-		// must be pointers to the place you want to copy the data into, and
-		// the number of arguments must be exactly the same as the number of
-		// columns returned by your statement.
-		err = rows.Scan(&s.ID, &s.Title, &s.Content, &s.Created, &s.Expires)
+		s, err := scanSession(rows)
 		if err != nil {
 			return nil, err
 		}
-		// Append it to the slice of snippets.
 		sessions = append(sessions, s)
 	}
 	// When the rows.Next() loop has finished we call rows.Err() to retrieve an
@@ -120,6 +277,311 @@ func (m *SessionModel) Latest() ([]*models.Session, error) {
 	if err = rows.Err(); err != nil {
 		return nil, err
 	}
-	// If everything went OK then return the sessions slice.
 	return sessions, nil
 }
+
+// Cancel a booked session, recording the user who cancelled it and when. If
+// the session does not exist, was already cancelled or already started,
+// models.ErrNoRecord is returned. The time slot of a cancelled session can be
+// booked again
+func (m *SessionModel) Cancel(id, userID int) error {
+	stmt := `UPDATE sessions SET status = 'cancelled', cancelled_by = ?,
+	    cancelled_at = UTC_TIMESTAMP()
+	    WHERE id = ? AND status = 'booked' AND start_time > UTC_TIMESTAMP()`
+	result, err := m.DB.Exec(stmt, userID, id)
+	if err != nil {
+		return err
+	}
+	return expectAffected(result)
+}
+
+// Update changes the court, the time slot, the price, the title and the
+// content of the booked session s, the user with the id userID made the
+// change. The previous court, title, content and time slot are kept as a
+// models.SessionChange, nothing is stored if nothing changed. If the session
+// was moved, the new slot is checked like a new booking: if it overlaps with
+// another session on the court, models.ErrSlotTaken is returned, if the court
+// is closed models.ErrCourtClosed, if the slot does not fit the grid of the
+// court models.ErrOffGrid, if the coach of a lesson is not available
+// models.ErrCoachUnavailable and if the rented equipment is not free
+// models.ErrEquipmentUnavailable. The check is run like for a new booking, if
+// it is not nil. If the session does not exist, was cancelled or was moved by
+// another request in the meantime, models.ErrNoRecord is returned
+func (m *SessionModel) Update(s *models.Session, userID int, check *models.BookingCheck) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// The session is read before taking the locks in the same order as a new
+	// booking: coach, court and then the sessions of the court. The coach of
+	// a lesson never changes
+	var coachID int
+	var old models.SessionChange
+	stmt := `SELECT IFNULL(coach_id, 0), court_id, start_time, end_time FROM sessions
+	    WHERE id = ? AND status = 'booked'`
+	err = tx.QueryRow(stmt, s.ID).Scan(&coachID, &old.CourtID, &old.Start, &old.End)
+	if err == sql.ErrNoRows {
+		return models.ErrNoRecord
+	} else if err != nil {
+		return err
+	}
+	slot := models.Slot{Start: s.Start, End: s.End}
+	// a session which stays in its slot is not checked again, e.g. its title
+	// can be changed even if the court was closed in the meantime
+	moved := s.CourtID != old.CourtID || !s.Start.Equal(old.Start) || !s.End.Equal(old.End)
+	if moved {
+		if coachID != 0 {
+			if err = lockCoach(tx, coachID, s.Start, s.End, s.ID); err != nil {
+				return err
+			}
+		}
+		if err = lockCourt(tx, s.CourtID); err != nil {
+			return err
+		}
+		if err = courtClosed(tx, s.CourtID, slot); err != nil {
+			return err
+		}
+		if err = courtOffGrid(tx, s.CourtID, slot); err != nil {
+			return err
+		}
+	}
+	var locked models.SessionChange
+	stmt = `SELECT court_id, title, content, start_time, end_time FROM sessions
+	    WHERE id = ? AND status = 'booked' FOR UPDATE`
+	err = tx.QueryRow(stmt, s.ID).Scan(&locked.CourtID, &locked.Title, &locked.Content, &locked.Start, &locked.End)
+	if err == sql.ErrNoRows {
+		return models.ErrNoRecord
+	} else if err != nil {
+		return err
+	}
+	// the checks above were made for the slot read before the locks
+	if locked.CourtID != old.CourtID || !locked.Start.Equal(old.Start) || !locked.End.Equal(old.End) {
+		return models.ErrNoRecord
+	}
+	old = locked
+	if !moved && s.Title == old.Title && s.Content == old.Content {
+		return nil
+	}
+	if moved {
+		taken, err := slotTaken(tx, s.CourtID, s.Start, s.End, s.ID)
+		if err != nil {
+			return err
+		}
+		if taken {
+			return models.ErrSlotTaken
+		}
+		if err = checkBooking(tx, check); err != nil {
+			return err
+		}
+		if err = moveRentals(tx, s.ID, slot); err != nil {
+			return err
+		}
+	}
+
+	stmt = `INSERT INTO session_changes (session_id, user_id, court_id, title, content, start_time, end_time, changed)
+	    VALUES(?, ?, ?, ?, ?, ?, ?, UTC_TIMESTAMP())`
+	_, err = tx.Exec(stmt, s.ID, userID, old.CourtID, old.Title, old.Content, old.Start, old.End)
+	if err != nil {
+		return err
+	}
+	stmt = `UPDATE sessions SET court_id = ?, start_time = ?, end_time = ?, price = ?,
+	    title = ?, content = ? WHERE id = ?`
+	_, err = tx.Exec(stmt, s.CourtID, s.Start.UTC(), s.End.UTC(), s.Price, s.Title, s.Content, s.ID)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Changes returns the changes made to a session, the latest change first
+func (m *SessionModel) Changes(sessionID int) ([]*models.SessionChange, error) {
+	stmt := `SELECT sc.id, sc.session_id, sc.user_id, u.name, sc.court_id, c.name,
+	    sc.title, sc.content, sc.start_time, sc.end_time, sc.changed
+	    FROM session_changes sc INNER JOIN users u ON sc.user_id = u.id
+	    INNER JOIN courts c ON sc.court_id = c.id
+	    WHERE sc.session_id = ? ORDER BY sc.changed DESC, sc.id DESC`
+	rows, err := m.DB.Query(stmt, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	changes := []*models.SessionChange{}
+	for rows.Next() {
+		c := &models.SessionChange{}
+		err = rows.Scan(&c.ID, &c.SessionID, &c.UserID, &c.UserName, &c.CourtID, &c.CourtName,
+			&c.Title, &c.Content, &c.Start, &c.End, &c.Changed)
+		if err != nil {
+			return nil, err
+		}
+		changes = append(changes, c)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return changes, nil
+}
+
+// CheckIn records that the players of a booked session arrived on the
+// court. If the session does not exist, was cancelled or somebody already
+// checked in, models.ErrNoRecord is returned
+func (m *SessionModel) CheckIn(id int) error {
+	stmt := `UPDATE sessions SET checked_in_at = UTC_TIMESTAMP()
+	    WHERE id = ? AND status = 'booked' AND checked_in_at IS NULL`
+	result, err := m.DB.Exec(stmt, id)
+	if err != nil {
+		return err
+	}
+	return expectAffected(result)
+}
+
+// MarkNoShows marks the booked sessions which ended between since and now
+// without a check-in as no-shows, it returns how many sessions were marked
+func (m *SessionModel) MarkNoShows(since, now time.Time) (int, error) {
+	stmt := `UPDATE sessions SET no_show = TRUE
+	    WHERE status = 'booked' AND checked_in_at IS NULL AND NOT no_show
+	    AND no_show_cleared_by IS NULL AND end_time > ? AND end_time < ?`
+	result, err := m.DB.Exec(stmt, since.UTC(), now.UTC())
+	if err != nil {
+		return 0, err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	return int(n), nil
+}
+
+// FlagNoShow marks a booked session as a no-show on behalf of the user who
+// flagged it. If the session does not exist, was cancelled, was checked in
+// to, is already a no-show or its no-show was cleared, models.ErrNoRecord is
+// returned
+func (m *SessionModel) FlagNoShow(id, userID int) error {
+	stmt := `UPDATE sessions SET no_show = TRUE, no_show_by = ?
+	    WHERE id = ? AND status = 'booked' AND checked_in_at IS NULL
+	    AND NOT no_show AND no_show_cleared_by IS NULL`
+	result, err := m.DB.Exec(stmt, userID, id)
+	if err != nil {
+		return err
+	}
+	return expectAffected(result)
+}
+
+// ClearNoShow removes the no-show of a session on behalf of the admin with
+// the id adminID, the session is not marked or flagged again afterwards. If
+// the session does not exist or is not a no-show, models.ErrNoRecord is
+// returned
+func (m *SessionModel) ClearNoShow(id, adminID int) error {
+	stmt := `UPDATE sessions SET no_show = FALSE, no_show_by = NULL, no_show_cleared_by = ?
+	    WHERE id = ? AND no_show`
+	result, err := m.DB.Exec(stmt, adminID, id)
+	if err != nil {
+		return err
+	}
+	return expectAffected(result)
+}
+
+// NoShows returns the no-shows of a user which ended after since, ordered by
+// their end time. If userID is 0, the no-shows of all users are returned
+func (m *SessionModel) NoShows(userID int, since time.Time) ([]*models.Session, error) {
+	stmt := `SELECT ` + sessionColumns + ` FROM ` + sessionTables + `
+	    WHERE (? = 0 OR s.user_id = ?) AND s.status = 'booked' AND s.no_show
+	    AND s.end_time > ?
+	    ORDER BY s.end_time`
+	return querySessions(m.DB, stmt, userID, userID, since.UTC())
+}
+
+// forUserQuery selects the booked sessions of a user which end after a time
+const forUserQuery = `SELECT ` + sessionColumns + ` FROM ` + sessionTables + `
+    WHERE s.user_id = ? AND s.status = 'booked' AND s.end_time > ?
+    ORDER BY s.start_time`
+
+// ForUser returns the booked sessions of a user which end after from,
+// ordered by their start time
+func (m *SessionModel) ForUser(userID int, from time.Time) ([]*models.Session, error) {
+	return querySessions(m.DB, forUserQuery, userID, from.UTC())
+}
+
+// checkBooking runs the booking check as part of the transaction tx, a nil
+// check always passes. The user is locked until the transaction ends, so that
+// no other booking of the user can be inserted in the meantime. The user is
+// locked after the coach and the court, like in every booking
+func checkBooking(tx *sql.Tx, check *models.BookingCheck) error {
+	if check == nil {
+		return nil
+	}
+	var id int
+	err := tx.QueryRow(`SELECT id FROM users WHERE id = ? FOR UPDATE`, check.UserID).Scan(&id)
+	if err == sql.ErrNoRows {
+		return models.ErrNoRecord
+	} else if err != nil {
+		return err
+	}
+	booked, err := querySessions(tx, forUserQuery, check.UserID, check.From.UTC())
+	if err != nil {
+		return err
+	}
+	return check.Verify(booked)
+}
+
+// Bookings returns the sessions in which a user takes part, either as the
+// user who booked them, as the coach or as a player who did not decline the
+// invitation. The filter selects the upcoming, past or cancelled sessions
+// (relative to now), the upcoming sessions are ordered by their start time
+// and the others from the latest to the oldest one. At most limit sessions
+// are returned, after skipping the first offset sessions
+func (m *SessionModel) Bookings(userID int, filter string, now time.Time, limit, offset int) ([]*models.Session, error) {
+	args := []interface{}{userID, userID, userID}
+	var where, order string
+	switch filter {
+	case models.BookingsUpcoming:
+		where, order = `s.status = 'booked' AND s.end_time > ?`, `s.start_time, s.id`
+		args = append(args, now.UTC())
+	case models.BookingsPast:
+		where, order = `s.status = 'booked' AND s.end_time <= ?`, `s.start_time DESC, s.id DESC`
+		args = append(args, now.UTC())
+	case models.BookingsCancelled:
+		where, order = `s.status = 'cancelled'`, `s.start_time DESC, s.id DESC`
+	default:
+		return nil, models.ErrNoRecord
+	}
+	stmt := `SELECT ` + sessionColumns + ` FROM ` + sessionTables + `
+	    WHERE (s.user_id = ? OR s.coach_id = ? OR EXISTS (SELECT 1 FROM participants p
+	        WHERE p.session_id = s.id AND p.user_id = ? AND p.status <> 'declined'))
+	    AND ` + where + `
+	    ORDER BY ` + order + ` LIMIT ? OFFSET ?`
+	args = append(args, limit, offset)
+	return querySessions(m.DB, stmt, args...)
+}
+
+// SetOpen opens a booked session to other members of a similar level, or
+// closes it. If the session does not exist or was cancelled,
+// models.ErrNoRecord is returned
+func (m *SessionModel) SetOpen(id int, open bool) error {
+	stmt := `UPDATE sessions SET is_open = ? WHERE id = ? AND status = 'booked'`
+	result, err := m.DB.Exec(stmt, open, id)
+	if err != nil {
+		return err
+	}
+	return expectAffected(result)
+}
+
+// OpenFor returns the upcoming open sessions which the user could join: they
+// were booked by another member of a similar level, they are not full yet and
+// the user is not already taking part in them
+func (m *SessionModel) OpenFor(u *models.User) ([]*models.Session, error) {
+	// the user who booked a session is also one of its players
+	stmt := `SELECT ` + sessionColumns + ` FROM ` + sessionTables + `
+	    WHERE s.is_open AND s.status = 'booked' AND s.start_time > UTC_TIMESTAMP()
+	    AND s.user_id <> ? AND ou.skill >= ? AND ou.skill BETWEEN ? AND ?
+	    AND NOT EXISTS (SELECT 1 FROM participants p
+	        WHERE p.session_id = s.id AND p.user_id = ?)
+	    AND (SELECT COUNT(*) FROM participants p
+	        WHERE p.session_id = s.id AND p.status <> 'declined') + 1
+	        < IF(s.kind = 'doubles', 4, 2)
+	    ORDER BY s.start_time`
+	return querySessions(m.DB, stmt, u.ID, models.MinSkill,
+		u.Skill-models.SkillTolerance, u.Skill+models.SkillTolerance, u.ID)
+}
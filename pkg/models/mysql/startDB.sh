@@ -1,3 +1,3 @@
 #!/bin/sh
 
-mariadb < sessionsTable.mysql && mariadb usersTable.mysql && echo "* DB correctly configured!"
+mariadb < sessionsTable.mysql && mariadb < usersTable.mysql && mariadb < tiersTable.mysql && mariadb < courtsTable.mysql && mariadb < seriesTable.mysql && mariadb < scheduleTable.mysql && mariadb < pricesTable.mysql && mariadb < waitlistTable.mysql && mariadb < participantsTable.mysql && mariadb < resultsTable.mysql && mariadb < ladderTable.mysql && mariadb < tournamentsTable.mysql && mariadb < coachesTable.mysql && mariadb < equipmentTable.mysql && mariadb < sessionChangesTable.mysql && echo "* DB correctly configured!"
@@ -0,0 +1,82 @@
+package mysql
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/erodrigufer/GoTennis/pkg/models"
+)
+
+// Define a TierModel type which wraps a sql.DB connection pool, it handles
+// the membership tiers of the club
+type TierModel struct {
+	DB *sql.DB
+}
+
+// Columns selected for every tier, the TIME columns are fetched as seconds
+// since midnight
+const tierColumns = `name, horizon_days, TIME_TO_SEC(opens), TIME_TO_SEC(closes),
+	max_active, max_weekly_minutes, max_weekly_prime`
+
+// scanTier copies the tierColumns of a row into a new Tier struct
+func scanTier(row scanner) (*models.Tier, error) {
+	t := &models.Tier{}
+	var opens, closes, weekly int
+	err := row.Scan(&t.Name, &t.Horizon, &opens, &closes,
+		&t.Quota.MaxActive, &weekly, &t.Quota.MaxWeeklyPrime)
+	if err != nil {
+		return nil, err
+	}
+	t.Opens = time.Duration(opens) * time.Second
+	t.Closes = time.Duration(closes) * time.Second
+	t.Quota.MaxWeekly = time.Duration(weekly) * time.Minute
+	return t, nil
+}
+
+// Get a tier from the db, using its name
+func (m *TierModel) Get(name string) (*models.Tier, error) {
+	stmt := `SELECT ` + tierColumns + ` FROM tiers WHERE name = ?`
+	t, err := scanTier(m.DB.QueryRow(stmt, name))
+	if err == sql.ErrNoRows {
+		return nil, models.ErrNoRecord
+	} else if err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// All returns all the tiers, ordered by how far ahead their members can book
+func (m *TierModel) All() ([]*models.Tier, error) {
+	rows, err := m.DB.Query(`SELECT ` + tierColumns + ` FROM tiers ORDER BY horizon_days DESC, name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tiers := []*models.Tier{}
+	for rows.Next() {
+		t, err := scanTier(rows)
+		if err != nil {
+			return nil, err
+		}
+		tiers = append(tiers, t)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return tiers, nil
+}
+
+// Update stores the booking rules of a tier, the tier is found by its name. If
+// the tier does not exist, models.ErrNoRecord is returned
+func (m *TierModel) Update(t *models.Tier) error {
+	stmt := `UPDATE tiers SET horizon_days = ?, opens = SEC_TO_TIME(?), closes = SEC_TO_TIME(?),
+	    max_active = ?, max_weekly_minutes = ?, max_weekly_prime = ?
+	    WHERE name = ?`
+	result, err := m.DB.Exec(stmt, t.Horizon, int(t.Opens/time.Second), int(t.Closes/time.Second),
+		t.Quota.MaxActive, int(t.Quota.MaxWeekly/time.Minute), t.Quota.MaxWeeklyPrime, t.Name)
+	if err != nil {
+		return err
+	}
+	return expectAffected(result)
+}
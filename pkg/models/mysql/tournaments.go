@@ -0,0 +1,326 @@
+package mysql
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+
+	"github.com/erodrigufer/GoTennis/pkg/models"
+
+	"github.com/go-sql-driver/mysql" // mysql driver
+)
+
+// Define a TournamentModel type which wraps a sql.DB connection pool
+type TournamentModel struct {
+	DB *sql.DB
+}
+
+// Insert a new tournament open for registration, it returns the id of the
+// newly inserted tournament
+func (m *TournamentModel) Insert(t *models.Tournament) (int, error) {
+	stmt := `INSERT INTO tournaments (name, format, start_date, match_minutes, created)
+	VALUES(?, ?, ?, ?, UTC_TIMESTAMP())`
+	result, err := m.DB.Exec(stmt, t.Name, t.Format, t.Start, int(t.MatchDuration/time.Minute))
+	if err != nil {
+		return 0, err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	return int(id), nil
+}
+
+// Columns selected for every tournament
+const tournamentColumns = `id, name, format, status, start_date, match_minutes, created`
+
+// scanTournament copies the tournamentColumns of a row into a new Tournament
+// struct
+func scanTournament(row scanner) (*models.Tournament, error) {
+	t := &models.Tournament{}
+	var minutes int
+	err := row.Scan(&t.ID, &t.Name, &t.Format, &t.Status, &t.Start, &minutes, &t.Created)
+	if err != nil {
+		return nil, err
+	}
+	t.MatchDuration = time.Duration(minutes) * time.Minute
+	return t, nil
+}
+
+// Get a tournament from the db, using its id
+func (m *TournamentModel) Get(id int) (*models.Tournament, error) {
+	stmt := `SELECT ` + tournamentColumns + ` FROM tournaments WHERE id = ?`
+	t, err := scanTournament(m.DB.QueryRow(stmt, id))
+	if err == sql.ErrNoRows {
+		return nil, models.ErrNoRecord
+	} else if err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// All returns all the tournaments, the latest first
+func (m *TournamentModel) All() ([]*models.Tournament, error) {
+	stmt := `SELECT ` + tournamentColumns + ` FROM tournaments ORDER BY start_date DESC, id DESC`
+	rows, err := m.DB.Query(stmt)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tournaments := []*models.Tournament{}
+	for rows.Next() {
+		t, err := scanTournament(rows)
+		if err != nil {
+			return nil, err
+		}
+		tournaments = append(tournaments, t)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return tournaments, nil
+}
+
+// Register a user for a tournament. If the user is already registered,
+// models.ErrAlreadyRegistered is returned
+func (m *TournamentModel) Register(tournamentID, userID int) error {
+	stmt := `INSERT INTO tournament_entries (tournament_id, user_id, created)
+	VALUES(?, ?, UTC_TIMESTAMP())`
+	_, err := m.DB.Exec(stmt, tournamentID, userID)
+	if err != nil {
+		// 1062 is the error code for duplicate entry
+		if mysqlErr, ok := err.(*mysql.MySQLError); ok {
+			if mysqlErr.Number == 1062 && strings.Contains(mysqlErr.Message, "tournament_entries_uc_user") {
+				return models.ErrAlreadyRegistered
+			}
+		}
+	}
+	return err
+}
+
+// Entries returns the players registered for a tournament, ordered by their
+// seed once the draw is made and by the time they registered before
+func (m *TournamentModel) Entries(tournamentID int) ([]*models.TournamentEntry, error) {
+	stmt := `SELECT e.tournament_id, e.user_id, u.name, e.seed, e.created
+	    FROM tournament_entries e INNER JOIN users u ON e.user_id = u.id
+	    WHERE e.tournament_id = ? ORDER BY e.seed = 0, e.seed, e.created, e.id`
+	rows, err := m.DB.Query(stmt, tournamentID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []*models.TournamentEntry{}
+	for rows.Next() {
+		e := &models.TournamentEntry{}
+		err = rows.Scan(&e.TournamentID, &e.UserID, &e.UserName, &e.Seed, &e.Created)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// Draw stores the draw of a tournament and closes its registration. seeded
+// holds the ids of the players in the order of their seeds. If the draw of
+// the tournament was already made, models.ErrNoRecord is returned
+func (m *TournamentModel) Draw(tournamentID int, seeded []int, matches []*models.TournamentMatch) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// only a single draw can change the status of the tournament
+	stmt := `UPDATE tournaments SET status = 'running' WHERE id = ? AND status = 'registration'`
+	result, err := tx.Exec(stmt, tournamentID)
+	if err != nil {
+		return err
+	}
+	if err = expectAffected(result); err != nil {
+		return err
+	}
+	stmt = `UPDATE tournament_entries SET seed = ? WHERE tournament_id = ? AND user_id = ?`
+	for i, userID := range seeded {
+		if _, err = tx.Exec(stmt, i+1, tournamentID, userID); err != nil {
+			return err
+		}
+	}
+	stmt = `INSERT INTO tournament_matches (tournament_id, round, position, home_id, away_id, winner_id, score)
+	VALUES(?, ?, ?, ?, ?, ?, ?)`
+	for _, match := range matches {
+		_, err = tx.Exec(stmt, tournamentID, match.Round, match.Position,
+			nullInt(match.HomeID), nullInt(match.AwayID), nullInt(match.WinnerID), match.Score)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// Columns selected for every match, they have to be selected from the
+// matchTables
+const matchColumns = `m.id, m.tournament_id, m.round, m.position, IFNULL(m.home_id, 0),
+	IFNULL(hu.name, ''), IFNULL(m.away_id, 0), IFNULL(au.name, ''), IFNULL(m.winner_id, 0),
+	IFNULL(m.session_id, 0), m.score`
+
+// Tables joined to select the matchColumns, the matches table is aliased as m
+const matchTables = `tournament_matches m LEFT JOIN users hu ON m.home_id = hu.id
+	LEFT JOIN users au ON m.away_id = au.id`
+
+// scanMatch copies the matchColumns of a row into a new TournamentMatch
+// struct
+func scanMatch(row scanner) (*models.TournamentMatch, error) {
+	t := &models.TournamentMatch{}
+	err := row.Scan(&t.ID, &t.TournamentID, &t.Round, &t.Position, &t.HomeID, &t.HomeName,
+		&t.AwayID, &t.AwayName, &t.WinnerID, &t.SessionID, &t.Score)
+	if err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// Matches returns all the matches of a tournament, ordered by round and
+// position
+func (m *TournamentModel) Matches(tournamentID int) ([]*models.TournamentMatch, error) {
+	stmt := `SELECT ` + matchColumns + ` FROM ` + matchTables + `
+	    WHERE m.tournament_id = ? ORDER BY m.round, m.position`
+	rows, err := m.DB.Query(stmt, tournamentID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	matches := []*models.TournamentMatch{}
+	for rows.Next() {
+		t, err := scanMatch(rows)
+		if err != nil {
+			return nil, err
+		}
+		matches = append(matches, t)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return matches, nil
+}
+
+// MatchForSession returns the tournament match played in a session. If the
+// session is not a tournament match, models.ErrNoRecord is returned
+func (m *TournamentModel) MatchForSession(sessionID int) (*models.TournamentMatch, error) {
+	stmt := `SELECT ` + matchColumns + ` FROM ` + matchTables + ` WHERE m.session_id = ?`
+	t, err := scanMatch(m.DB.QueryRow(stmt, sessionID))
+	if err == sql.ErrNoRows {
+		return nil, models.ErrNoRecord
+	} else if err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// BookMatch books the session s in which a match is played, the user who
+// booked it is the home player and the away player takes part in it. The
+// match is locked for the whole transaction: if it already has a session or a
+// winner (e.g. because it was scheduled concurrently), models.ErrNoRecord is
+// returned. The court is checked like for any other booking, if it is closed
+// models.ErrCourtClosed is returned and if the slot is taken
+// models.ErrSlotTaken. It returns the id of the new session
+func (m *TournamentModel) BookMatch(matchID int, s *models.Session, awayID int) (int, error) {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var id int
+	stmt := `SELECT id FROM tournament_matches
+	    WHERE id = ? AND session_id IS NULL AND winner_id IS NULL FOR UPDATE`
+	err = tx.QueryRow(stmt, matchID).Scan(&id)
+	if err == sql.ErrNoRows {
+		return 0, models.ErrNoRecord
+	} else if err != nil {
+		return 0, err
+	}
+	if err = lockCourt(tx, s.CourtID); err != nil {
+		return 0, err
+	}
+	if err = courtClosed(tx, s.CourtID, models.Slot{Start: s.Start, End: s.End}); err != nil {
+		return 0, err
+	}
+	if err = courtOffGrid(tx, s.CourtID, models.Slot{Start: s.Start, End: s.End}); err != nil {
+		return 0, err
+	}
+	taken, err := slotTaken(tx, s.CourtID, s.Start, s.End, 0)
+	if err != nil {
+		return 0, err
+	}
+	if taken {
+		return 0, models.ErrSlotTaken
+	}
+	sessionID, err := insertSession(tx, s)
+	if err != nil {
+		return 0, err
+	}
+	stmt = `INSERT INTO participants (session_id, user_id, status, invited, responded)
+	VALUES(?, ?, 'accepted', UTC_TIMESTAMP(), UTC_TIMESTAMP())`
+	if _, err = tx.Exec(stmt, sessionID, awayID); err != nil {
+		return 0, err
+	}
+	stmt = `UPDATE tournament_matches SET session_id = ? WHERE id = ?`
+	if _, err = tx.Exec(stmt, sessionID, matchID); err != nil {
+		return 0, err
+	}
+	if err = tx.Commit(); err != nil {
+		return 0, err
+	}
+	return sessionID, nil
+}
+
+// recordWinner stores the winner and the score of a match as part of the
+// transaction tx. In a knockout draw the winner advances to the match of the
+// next round, the tournament is finished once its last match was played. If
+// the match already has a winner, models.ErrResultExists is returned
+func recordWinner(tx *sql.Tx, matchID, winnerID int, score string) error {
+	var tournamentID, round, position, winner int
+	var format string
+	stmt := `SELECT m.tournament_id, m.round, m.position, IFNULL(m.winner_id, 0), t.format
+	    FROM tournament_matches m INNER JOIN tournaments t ON m.tournament_id = t.id
+	    WHERE m.id = ? FOR UPDATE`
+	err := tx.QueryRow(stmt, matchID).Scan(&tournamentID, &round, &position, &winner, &format)
+	if err == sql.ErrNoRows {
+		return models.ErrNoRecord
+	} else if err != nil {
+		return err
+	}
+	if winner != 0 {
+		return models.ErrResultExists
+	}
+	stmt = `UPDATE tournament_matches SET winner_id = ?, score = ? WHERE id = ?`
+	if _, err = tx.Exec(stmt, winnerID, score, matchID); err != nil {
+		return err
+	}
+
+	if format == models.FormatSingleElimination {
+		// the winners of the matches at the positions 2n and 2n+1 meet at
+		// the position n of the next round
+		column := "home_id"
+		if position%2 == 1 {
+			column = "away_id"
+		}
+		stmt = `UPDATE tournament_matches SET ` + column + ` = ?
+		    WHERE tournament_id = ? AND round = ? AND position = ?`
+		if _, err = tx.Exec(stmt, winnerID, tournamentID, round+1, position/2); err != nil {
+			return err
+		}
+	}
+	// the tournament is finished once all its matches have a winner
+	stmt = `UPDATE tournaments SET status = 'finished' WHERE id = ? AND NOT EXISTS
+	    (SELECT 1 FROM tournament_matches WHERE tournament_id = ? AND winner_id IS NULL)`
+	_, err = tx.Exec(stmt, tournamentID, tournamentID)
+	return err
+}
@@ -76,12 +76,78 @@ func (m *UserModel) Authenticate(email, password string) (int, error) {
 	return id, nil
 }
 
+// Columns selected for every user
+const userColumns = `id, name, email, role, tier, skill, play_times, timezone, created`
+
+// scanUser copies the userColumns of a row into a new User struct
+func scanUser(row scanner) (*models.User, error) {
+	u := &models.User{}
+	var playTimes string
+	err := row.Scan(&u.ID, &u.Name, &u.Email, &u.Role, &u.Tier, &u.Skill, &playTimes, &u.TimeZone, &u.Created)
+	if err != nil {
+		return nil, err
+	}
+	// the values of a SET column are separated by commas
+	u.PlayTimes = []string{}
+	if playTimes != "" {
+		u.PlayTimes = strings.Split(playTimes, ",")
+	}
+	return u, nil
+}
+
+// queryUsers runs a query selecting the userColumns and returns all the users
+// of the resultset
+func queryUsers(q queryer, stmt string, args ...interface{}) ([]*models.User, error) {
+	rows, err := q.Query(stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := []*models.User{}
+	for rows.Next() {
+		u, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, u)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+// All returns all the users ordered by name
+func (m *UserModel) All() ([]*models.User, error) {
+	return queryUsers(m.DB, `SELECT `+userColumns+` FROM users ORDER BY name`)
+}
+
+// SetTier moves a user to another membership tier. If the user does not
+// exist, models.ErrNoRecord is returned
+func (m *UserModel) SetTier(id int, tier string) error {
+	result, err := m.DB.Exec(`UPDATE users SET tier = ? WHERE id = ?`, tier, id)
+	if err != nil {
+		return err
+	}
+	return expectAffected(result)
+}
+
+// SetRole changes the role of a user, e.g. to make a member a coach. If the
+// user does not exist, models.ErrNoRecord is returned
+func (m *UserModel) SetRole(id int, role string) error {
+	result, err := m.DB.Exec(`UPDATE users SET role = ? WHERE id = ?`, role, id)
+	if err != nil {
+		return err
+	}
+	return expectAffected(result)
+}
+
 // Fetch details for a specific user based on its userID (userID as input
 // parameter)
 func (m *UserModel) Get(id int) (*models.User, error) {
-	s := &models.User{}
-	stmt := `SELECT id, name, email, created FROM users WHERE id = ?`
-	err := m.DB.QueryRow(stmt, id).Scan(&s.ID, &s.Name, &s.Email, &s.Created)
+	stmt := `SELECT ` + userColumns + ` FROM users WHERE id = ?`
+	s, err := scanUser(m.DB.QueryRow(stmt, id))
 	// error, user does not exist
 	if err == sql.ErrNoRows {
 		return nil, models.ErrNoRecord
@@ -90,3 +156,57 @@ func (m *UserModel) Get(id int) (*models.User, error) {
 	}
 	return s, nil
 }
+
+// Fetch details for a specific user based on its email address
+func (m *UserModel) GetByEmail(email string) (*models.User, error) {
+	stmt := `SELECT ` + userColumns + ` FROM users WHERE email = ?`
+	s, err := scanUser(m.DB.QueryRow(stmt, email))
+	if err == sql.ErrNoRows {
+		return nil, models.ErrNoRecord
+	} else if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// UpdateProfile stores the NTRP rating, the preferred times to play and the
+// time zone of a user. If the user does not exist, models.ErrNoRecord is
+// returned
+func (m *UserModel) UpdateProfile(id int, skill float64, playTimes []string, timeZone string) error {
+	stmt := `UPDATE users SET skill = ?, play_times = ?, timezone = ? WHERE id = ?`
+	result, err := m.DB.Exec(stmt, skill, strings.Join(playTimes, ","), timeZone, id)
+	if err != nil {
+		return err
+	}
+	return expectAffected(result)
+}
+
+// Partners returns the members which are compatible with the user (of a
+// similar level and sharing a preferred time to play), ordered by how close
+// their rating is to the rating of the user
+func (m *UserModel) Partners(u *models.User) ([]*models.User, error) {
+	stmt := `SELECT ` + userColumns + ` FROM users
+	    WHERE id <> ? AND skill >= ? AND skill BETWEEN ? AND ?
+	    ORDER BY ABS(skill - ?), name`
+	rows, err := m.DB.Query(stmt, u.ID, models.MinSkill,
+		u.Skill-models.SkillTolerance, u.Skill+models.SkillTolerance, u.Skill)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	partners := []*models.User{}
+	for rows.Next() {
+		p, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		if u.Compatible(p) {
+			partners = append(partners, p)
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return partners, nil
+}
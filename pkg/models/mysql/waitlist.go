@@ -0,0 +1,185 @@
+package mysql
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+
+	"github.com/erodrigufer/GoTennis/pkg/models"
+
+	"github.com/go-sql-driver/mysql" // mysql driver
+)
+
+// Define a WaitlistModel type which wraps a sql.DB connection pool
+type WaitlistModel struct {
+	DB *sql.DB
+}
+
+// Insert a user into the waitlist of a slot on a court, the session booked
+// for the user is of the given kind and is charged the guest rate if guests
+// is true. If the user is already waiting for that slot,
+// models.ErrAlreadyQueued is returned
+func (m *WaitlistModel) Insert(userID, courtID int, start, end time.Time, kind string, guests bool, title string) (int, error) {
+	stmt := `INSERT INTO waitlist (court_id, start_time, end_time, user_id, kind, guests, title, created)
+	VALUES(?, ?, ?, ?, ?, ?, ?, UTC_TIMESTAMP())`
+	result, err := m.DB.Exec(stmt, courtID, start.UTC(), end.UTC(), userID, kind, guests, title)
+	if err != nil {
+		// 1062 is the error code for duplicate entry
+		if mysqlErr, ok := err.(*mysql.MySQLError); ok {
+			if mysqlErr.Number == 1062 && strings.Contains(mysqlErr.Message, "waitlist_uc_slot_user") {
+				return 0, models.ErrAlreadyQueued
+			}
+		}
+		return 0, err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	return int(id), nil
+}
+
+// Delete removes a user from the waitlist of a slot on a court
+func (m *WaitlistModel) Delete(userID, courtID int, start, end time.Time) error {
+	stmt := `DELETE FROM waitlist
+	    WHERE user_id = ? AND court_id = ? AND start_time = ? AND end_time = ?`
+	result, err := m.DB.Exec(stmt, userID, courtID, start.UTC(), end.UTC())
+	if err != nil {
+		return err
+	}
+	return expectAffected(result)
+}
+
+// ForSlot returns the users waiting for a slot on a court, in the order in
+// which they joined the waitlist
+func (m *WaitlistModel) ForSlot(courtID int, start, end time.Time) ([]*models.WaitlistEntry, error) {
+	stmt := `SELECT w.id, w.court_id, w.start_time, w.end_time, w.user_id, u.name,
+	    w.kind, w.guests, w.title, w.created
+	    FROM waitlist w INNER JOIN users u ON w.user_id = u.id
+	    WHERE w.court_id = ? AND w.start_time = ? AND w.end_time = ?
+	    ORDER BY w.created, w.id`
+	rows, err := m.DB.Query(stmt, courtID, start.UTC(), end.UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []*models.WaitlistEntry{}
+	for rows.Next() {
+		e := &models.WaitlistEntry{}
+		err = rows.Scan(&e.ID, &e.CourtID, &e.Start, &e.End, &e.UserID, &e.UserName, &e.Kind, &e.Guests, &e.Title, &e.Created)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// Promote books a freed slot on a court for the first user of the waitlist.
+// Every entry overlapping the slot is considered in the order in which the
+// users joined, the first entry whose slot is free again gets booked and is
+// removed from the waitlist. Entries whose slot is closed are skipped, as well
+// as the entries whose booking is refused by the check returned for them
+// (e.g. because of the quota of the user). The new session is charged the
+// rate of the entry. It returns the promoted entry and the id of the new
+// session, or a nil entry if nobody could be promoted
+func (m *WaitlistModel) Promote(courtID int, slot models.Slot, pricing *models.Pricing, check func(e *models.WaitlistEntry) (*models.BookingCheck, error)) (*models.WaitlistEntry, int, error) {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return nil, 0, err
+	}
+	defer tx.Rollback()
+
+	// the court is locked, so that no other booking can take the slot while
+	// the waitlist is processed
+	if err = lockCourt(tx, courtID); err != nil {
+		return nil, 0, err
+	}
+	stmt := `SELECT id, court_id, start_time, end_time, user_id, kind, guests, title, created
+	    FROM waitlist
+	    WHERE court_id = ? AND start_time < ? AND end_time > ? AND start_time > UTC_TIMESTAMP()
+	    ORDER BY created, id
+	    FOR UPDATE`
+	rows, err := tx.Query(stmt, courtID, slot.End.UTC(), slot.Start.UTC())
+	if err != nil {
+		return nil, 0, err
+	}
+	entries := []*models.WaitlistEntry{}
+	for rows.Next() {
+		e := &models.WaitlistEntry{}
+		err = rows.Scan(&e.ID, &e.CourtID, &e.Start, &e.End, &e.UserID, &e.Kind, &e.Guests, &e.Title, &e.Created)
+		if err != nil {
+			rows.Close()
+			return nil, 0, err
+		}
+		entries = append(entries, e)
+	}
+	// the resultset has to be closed before running other statements on the
+	// same transaction
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	for _, e := range entries {
+		// the opening hours and the prices are taken in the time zone of the
+		// freed slot
+		entrySlot := models.Slot{Start: e.Start.In(slot.Start.Location()), End: e.End.In(slot.Start.Location())}
+		err = courtClosed(tx, courtID, entrySlot)
+		if err == models.ErrCourtClosed {
+			continue
+		} else if err != nil {
+			return nil, 0, err
+		}
+		// the grid of the court may have changed since the user joined
+		err = courtOffGrid(tx, courtID, entrySlot)
+		if err == models.ErrOffGrid {
+			continue
+		} else if err != nil {
+			return nil, 0, err
+		}
+		taken, err := slotTaken(tx, courtID, e.Start, e.End, 0)
+		if err != nil {
+			return nil, 0, err
+		}
+		if taken {
+			continue
+		}
+		c, err := check(e)
+		if err != nil {
+			return nil, 0, err
+		}
+		err = checkBooking(tx, c)
+		if err == models.ErrBookingRefused || err == models.ErrNoRecord {
+			continue
+		} else if err != nil {
+			return nil, 0, err
+		}
+		id, err := insertSession(tx, &models.Session{
+			UserID:  e.UserID,
+			CourtID: courtID,
+			Kind:    e.Kind,
+			Guests:  e.Guests,
+			Price:   pricing.Price(courtID, entrySlot, e.Guests),
+			Title:   e.Title,
+			Content: "Booked from the waitlist",
+			Start:   e.Start,
+			End:     e.End,
+		})
+		if err != nil {
+			return nil, 0, err
+		}
+		if _, err = tx.Exec(`DELETE FROM waitlist WHERE id = ?`, e.ID); err != nil {
+			return nil, 0, err
+		}
+		if err = tx.Commit(); err != nil {
+			return nil, 0, err
+		}
+		return e, id, nil
+	}
+	return nil, 0, nil
+}
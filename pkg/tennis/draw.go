@@ -0,0 +1,80 @@
+package tennis
+
+// Bye marks the missing opponent of a player who advances without playing
+const Bye = -1
+
+// A Pairing is a match of a draw between two players, identified by their
+// index in the list of players sorted by seed (0 is the top seed). One of
+// them is Bye if the other player has no opponent
+type Pairing struct {
+	Home, Away int
+}
+
+// SingleElimination returns the pairings of the first round of a knockout
+// draw for n players sorted by seed, and the number of rounds of the draw.
+// The size of the draw is the next power of 2, the top seeds get the byes and
+// the seeds are placed so that the top seeds can only meet in the last rounds
+func SingleElimination(n int) ([]Pairing, int) {
+	if n < 2 {
+		return []Pairing{}, 0
+	}
+	size, rounds := 1, 0
+	for size < n {
+		size *= 2
+		rounds++
+	}
+	// order holds the seeds (starting at 1) in the order of the lines of the
+	// draw: 1 2, then 1 4 2 3, then 1 8 4 5 2 7 3 6, ...
+	order := []int{1}
+	for len(order) < size {
+		next := make([]int, 0, 2*len(order))
+		for _, s := range order {
+			next = append(next, s, 2*len(order)+1-s)
+		}
+		order = next
+	}
+	pairings := make([]Pairing, 0, size/2)
+	for i := 0; i < size; i += 2 {
+		pairings = append(pairings, Pairing{Home: player(order[i], n), Away: player(order[i+1], n)})
+	}
+	return pairings, rounds
+}
+
+// player returns the index of the player with the seed, or Bye if there are
+// fewer players than seeds
+func player(seed, n int) int {
+	if seed > n {
+		return Bye
+	}
+	return seed - 1
+}
+
+// RoundRobin returns the rounds of a draw in which each of the n players
+// plays once against every other player, using the circle method. If n is odd,
+// one player has a bye in every round
+func RoundRobin(n int) [][]Pairing {
+	if n < 2 {
+		return [][]Pairing{}
+	}
+	players := make([]int, n)
+	for i := range players {
+		players[i] = i
+	}
+	if n%2 == 1 {
+		players = append(players, Bye)
+	}
+	m := len(players)
+	rounds := make([][]Pairing, 0, m-1)
+	for r := 0; r < m-1; r++ {
+		round := make([]Pairing, 0, m/2)
+		for i := 0; i < m/2; i++ {
+			round = append(round, Pairing{Home: players[i], Away: players[m-1-i]})
+		}
+		rounds = append(rounds, round)
+		// keep the first player in place and rotate all the others
+		last := players[m-1]
+		copy(players[2:], players[1:m-1])
+		players[1] = last
+	}
+	return rounds
+}
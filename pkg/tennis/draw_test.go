@@ -0,0 +1,71 @@
+package tennis
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSingleElimination(t *testing.T) {
+	tests := []struct {
+		name     string
+		n        int
+		expected []Pairing
+		rounds   int
+	}{
+		{name: "Two", n: 2, expected: []Pairing{{0, 1}}, rounds: 1},
+		{name: "Four", n: 4, expected: []Pairing{{0, 3}, {1, 2}}, rounds: 2},
+		{
+			name:     "FiveWithByes",
+			n:        5,
+			expected: []Pairing{{0, Bye}, {3, 4}, {1, Bye}, {2, Bye}},
+			rounds:   3,
+		},
+		{name: "One", n: 1, expected: []Pairing{}, rounds: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pairings, rounds := SingleElimination(tt.n)
+			if !reflect.DeepEqual(pairings, tt.expected) {
+				t.Errorf("expected %v; got %v", tt.expected, pairings)
+			}
+			if rounds != tt.rounds {
+				t.Errorf("expected %d rounds; got %d", tt.rounds, rounds)
+			}
+		})
+	}
+}
+
+func TestRoundRobin(t *testing.T) {
+	for _, n := range []int{2, 4, 5} {
+		rounds := RoundRobin(n)
+		// every pair of players has to meet exactly once
+		met := map[[2]int]int{}
+		for _, round := range rounds {
+			playing := map[int]bool{}
+			for _, p := range round {
+				for _, i := range []int{p.Home, p.Away} {
+					if i != Bye && playing[i] {
+						t.Errorf("%d players: player %d plays twice in a round", n, i)
+					}
+					playing[i] = true
+				}
+				if p.Home == Bye || p.Away == Bye {
+					continue
+				}
+				a, b := p.Home, p.Away
+				if a > b {
+					a, b = b, a
+				}
+				met[[2]int{a, b}]++
+			}
+		}
+		if len(met) != n*(n-1)/2 {
+			t.Errorf("%d players: expected %d matches; got %d", n, n*(n-1)/2, len(met))
+		}
+		for pair, count := range met {
+			if count != 1 {
+				t.Errorf("%d players: expected %v to meet once; got %d", n, pair, count)
+			}
+		}
+	}
+}
@@ -0,0 +1,26 @@
+package tennis
+
+import "math"
+
+// InitialRating is the Elo rating of a player joining the ladder
+const InitialRating = 1500
+
+// ExpectedScore returns the probability that a player rated a beats a player
+// rated b, according to the Elo rating system
+func ExpectedScore(a, b int) float64 {
+	return 1 / (1 + math.Pow(10, float64(b-a)/400))
+}
+
+// Elo returns the new ratings of the winner and the loser of a match, k is the
+// largest change of a rating caused by a single match (usually 32)
+func Elo(winner, loser int, k float64) (int, int) {
+	change := int(math.Round(k * (1 - ExpectedScore(winner, loser))))
+	return winner + change, loser - change
+}
+
+// CanChallenge reports if the player on the rung challenger of the ladder
+// (1 is the top) is allowed to challenge the player on the rung challenged.
+// Players can only challenge players above them, at most reach rungs higher
+func CanChallenge(challenger, challenged, reach int) bool {
+	return challenged < challenger && challenger-challenged <= reach
+}
@@ -0,0 +1,46 @@
+package tennis
+
+import "testing"
+
+func TestElo(t *testing.T) {
+	tests := []struct {
+		name           string
+		winner, loser  int
+		expectedWinner int
+		expectedLoser  int
+	}{
+		{name: "Equal", winner: 1500, loser: 1500, expectedWinner: 1516, expectedLoser: 1484},
+		{name: "Favourite", winner: 1700, loser: 1500, expectedWinner: 1708, expectedLoser: 1492},
+		{name: "Upset", winner: 1500, loser: 1700, expectedWinner: 1524, expectedLoser: 1676},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w, l := Elo(tt.winner, tt.loser, 32)
+			if w != tt.expectedWinner || l != tt.expectedLoser {
+				t.Errorf("expected %d-%d; got %d-%d", tt.expectedWinner, tt.expectedLoser, w, l)
+			}
+		})
+	}
+}
+
+func TestCanChallenge(t *testing.T) {
+	tests := []struct {
+		name                   string
+		challenger, challenged int
+		expected               bool
+	}{
+		{name: "OneAbove", challenger: 5, challenged: 4, expected: true},
+		{name: "ReachAbove", challenger: 5, challenged: 2, expected: true},
+		{name: "TooFarAbove", challenger: 5, challenged: 1, expected: false},
+		{name: "Below", challenger: 5, challenged: 6, expected: false},
+		{name: "Self", challenger: 5, challenged: 5, expected: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ok := CanChallenge(tt.challenger, tt.challenged, 3)
+			if ok != tt.expected {
+				t.Errorf("expected %t; got %t", tt.expected, ok)
+			}
+		})
+	}
+}
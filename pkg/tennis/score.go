@@ -0,0 +1,237 @@
+// Package tennis implements the rules of tennis which are independent of the
+// storage, like the validation of match scores, the ratings of the ladder or
+// the draws of tournaments
+package tennis
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// How the final set of a match is decided when it reaches 6-6
+const (
+	FinalSetTiebreak      = "tiebreak"       // a regular 7 point tiebreak
+	FinalSetAdvantage     = "advantage"      // played until a player leads by 2 games
+	FinalSetSuperTiebreak = "super-tiebreak" // replaced by a 10 point match tiebreak
+)
+
+// A Format describes the rules under which a match is played
+type Format struct {
+	BestOf   int    // 3 or 5 sets
+	FinalSet string // one of the FinalSet constants
+}
+
+// Sides of a match, the home side is the player (or team) who booked the
+// session
+const (
+	Home = "home"
+	Away = "away"
+)
+
+// A Set is the result of a single set, from the point of view of the home
+// side. If the set was decided by a tiebreak, the points of the tiebreak are
+// stored too. A super tiebreak replacing the final set only stores the points
+// in Home and Away
+type Set struct {
+	Home, Away                 int
+	TiebreakHome, TiebreakAway int
+	Super                      bool // the set is a super tiebreak
+}
+
+// Winner returns the side which won the set
+func (s Set) Winner() string {
+	if s.Home > s.Away {
+		return Home
+	}
+	return Away
+}
+
+// String returns the set in the usual notation, like '6-4', '7-6(7-5)' or
+// '[10-8]' for a super tiebreak
+func (s Set) String() string {
+	if s.Super {
+		return fmt.Sprintf("[%d-%d]", s.Home, s.Away)
+	}
+	if s.TiebreakHome != 0 || s.TiebreakAway != 0 {
+		return fmt.Sprintf("%d-%d(%d-%d)", s.Home, s.Away, s.TiebreakHome, s.TiebreakAway)
+	}
+	return fmt.Sprintf("%d-%d", s.Home, s.Away)
+}
+
+// A Score holds all the sets of a match
+type Score []Set
+
+// String returns the score in the usual notation, like '6-4 3-6 7-6(7-5)'
+func (sc Score) String() string {
+	sets := make([]string, len(sc))
+	for i, s := range sc {
+		sets[i] = s.String()
+	}
+	return strings.Join(sets, " ")
+}
+
+// Winner returns the side which won more sets
+func (sc Score) Winner() string {
+	home := 0
+	for _, s := range sc {
+		if s.Winner() == Home {
+			home++
+		}
+	}
+	if 2*home > len(sc) {
+		return Home
+	}
+	return Away
+}
+
+// ErrInvalidScore is wrapped by all the errors returned when a score cannot
+// be parsed or is not possible. The messages of those errors explain why, so
+// that they can be displayed to the user
+var ErrInvalidScore = errors.New("invalid score")
+
+// a set like '6-4', '7-6(7-5)', '7-6(5)' or a super tiebreak like '[10-8]'
+var setRX = regexp.MustCompile(`^(?:(\d{1,2})-(\d{1,2})(?:\((\d{1,2})(?:-(\d{1,2}))?\))?|\[(\d{1,2})-(\d{1,2})\])$`)
+
+// ParseScore parses the score of a match written from the point of view of
+// the home side, its sets separated by spaces (e.g. '6-4 3-6 7-6(7-5)'), and
+// validates it against the format. The points of a tiebreak can be given in
+// full ('7-6(9-7)') or, as usual, only the points of the loser ('7-6(7)')
+func ParseScore(s string, f Format) (Score, error) {
+	fields := strings.Fields(s)
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("%w: the score is empty", ErrInvalidScore)
+	}
+	score := Score{}
+	for i, field := range fields {
+		m := setRX.FindStringSubmatch(field)
+		if m == nil {
+			return nil, fmt.Errorf("%w: set %d (%q) is not written like 6-4 or 7-6(7-5)", ErrInvalidScore, i+1, field)
+		}
+		set := Set{}
+		if m[5] != "" {
+			set.Super = true
+			set.Home, _ = strconv.Atoi(m[5])
+			set.Away, _ = strconv.Atoi(m[6])
+		} else {
+			set.Home, _ = strconv.Atoi(m[1])
+			set.Away, _ = strconv.Atoi(m[2])
+			if m[3] != "" {
+				a, _ := strconv.Atoi(m[3])
+				if m[4] != "" {
+					b, _ := strconv.Atoi(m[4])
+					set.TiebreakHome, set.TiebreakAway = a, b
+				} else {
+					// only the points of the loser of the tiebreak were given
+					set.TiebreakHome, set.TiebreakAway = tiebreakPoints(a, set.Home > set.Away)
+				}
+			}
+		}
+		score = append(score, set)
+	}
+	if err := score.Validate(f); err != nil {
+		return nil, err
+	}
+	return score, nil
+}
+
+// tiebreakPoints returns the points of a 7 point tiebreak from the points of
+// its loser
+func tiebreakPoints(loser int, homeWon bool) (int, int) {
+	winner := 7
+	if loser+2 > winner {
+		winner = loser + 2
+	}
+	if homeWon {
+		return winner, loser
+	}
+	return loser, winner
+}
+
+// Validate checks that the score is a possible result of a complete match
+// played under the format
+func (sc Score) Validate(f Format) error {
+	if f.BestOf != 3 && f.BestOf != 5 {
+		return fmt.Errorf("%w: a match is played over 3 or 5 sets", ErrInvalidScore)
+	}
+	needed := f.BestOf/2 + 1
+	home, away := 0, 0
+	for i, s := range sc {
+		if home == needed || away == needed {
+			return fmt.Errorf("%w: the match was already over after set %d", ErrInvalidScore, i)
+		}
+		final := i == f.BestOf-1
+		if err := validateSet(s, final, f); err != nil {
+			return fmt.Errorf("%w: set %d (%s) %s", ErrInvalidScore, i+1, s, err)
+		}
+		if s.Winner() == Home {
+			home++
+		} else {
+			away++
+		}
+	}
+	if home != needed && away != needed {
+		return fmt.Errorf("%w: the match is not complete, a player has to win %d sets", ErrInvalidScore, needed)
+	}
+	return nil
+}
+
+// validateSet checks the result of a single set, final reports if it is the
+// deciding set of the match. The returned error only holds the reason
+func validateSet(s Set, final bool, f Format) error {
+	if s.Super {
+		if !final || f.FinalSet != FinalSetSuperTiebreak {
+			return errors.New("can only be a super tiebreak if it is the final set")
+		}
+		return validateTiebreak(s.Home, s.Away, 10)
+	}
+	if final && f.FinalSet == FinalSetSuperTiebreak {
+		return errors.New("has to be a super tiebreak like [10-8]")
+	}
+	w, l := s.Home, s.Away
+	if l > w {
+		w, l = l, w
+	}
+	tiebreak := s.TiebreakHome != 0 || s.TiebreakAway != 0
+	if final && f.FinalSet == FinalSetAdvantage {
+		if tiebreak {
+			return errors.New("cannot have a tiebreak, the final set is played with advantage")
+		}
+		if w == 6 && l <= 4 || w > 6 && w-l == 2 {
+			return nil
+		}
+		return errors.New("has to be won by 6 games to at most 4, or by 2 games after 5-5")
+	}
+	switch {
+	case w == 6 && l <= 4, w == 7 && l == 5:
+		if tiebreak {
+			return errors.New("cannot have a tiebreak, it was not 6-6")
+		}
+		return nil
+	case w == 7 && l == 6:
+		if !tiebreak {
+			return errors.New("was decided by a tiebreak, its points are missing (like 7-6(7-5))")
+		}
+		if (s.TiebreakHome > s.TiebreakAway) != (s.Home > s.Away) {
+			return errors.New("was won by the loser of the tiebreak")
+		}
+		return validateTiebreak(s.TiebreakHome, s.TiebreakAway, 7)
+	default:
+		return errors.New("is not possible, a set is won 6-0 to 6-4, 7-5 or 7-6")
+	}
+}
+
+// validateTiebreak checks the points of a tiebreak played to target points,
+// which has to be won by 2 points
+func validateTiebreak(a, b, target int) error {
+	w, l := a, b
+	if l > w {
+		w, l = l, w
+	}
+	if w == target && l <= target-2 || w > target && w-l == 2 {
+		return nil
+	}
+	return fmt.Errorf("has an impossible tiebreak, it is won with %d points and by 2 points", target)
+}
@@ -0,0 +1,63 @@
+package tennis
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseScore(t *testing.T) {
+	bestOf3 := Format{BestOf: 3, FinalSet: FinalSetTiebreak}
+	advantage := Format{BestOf: 3, FinalSet: FinalSetAdvantage}
+	super := Format{BestOf: 3, FinalSet: FinalSetSuperTiebreak}
+	bestOf5 := Format{BestOf: 5, FinalSet: FinalSetTiebreak}
+	tests := []struct {
+		name     string
+		score    string
+		format   Format
+		expected string // canonical score, empty if the score is invalid
+		winner   string
+	}{
+		{name: "StraightSets", score: "6-4 6-3", format: bestOf3, expected: "6-4 6-3", winner: Home},
+		{name: "ThreeSets", score: "4-6 7-5 6-7(5)", format: bestOf3, expected: "4-6 7-5 6-7(5-7)", winner: Away},
+		{name: "LongTiebreak", score: "7-6(12-10) 6-0", format: bestOf3, expected: "7-6(12-10) 6-0", winner: Home},
+		{name: "ShortTiebreakNotation", score: "7-6(9) 6-0", format: bestOf3, expected: "7-6(11-9) 6-0", winner: Home},
+		{name: "AdvantageFinalSet", score: "6-4 4-6 12-10", format: advantage, expected: "6-4 4-6 12-10", winner: Home},
+		{name: "SuperTiebreak", score: "6-4 4-6 [8-10]", format: super, expected: "6-4 4-6 [8-10]", winner: Away},
+		{name: "BestOf5", score: "6-4 4-6 6-3 6-2", format: bestOf5, expected: "6-4 4-6 6-3 6-2", winner: Home},
+		{name: "Empty", score: " ", format: bestOf3},
+		{name: "Garbage", score: "six-four", format: bestOf3},
+		{name: "FinalSet6-5", score: "6-4 4-6 6-5", format: bestOf3},
+		{name: "TiebreakSet8-6", score: "8-6 6-4", format: bestOf3},
+		{name: "AdvantageOnlyInFinalSet", score: "8-6 4-6 12-10", format: advantage},
+		{name: "TiebreakIn6-4", score: "6-4(7-5) 6-4", format: bestOf3},
+		{name: "MissingTiebreak", score: "7-6 6-4", format: bestOf3},
+		{name: "TiebreakNotWonBy2", score: "7-6(7-6) 6-4", format: bestOf3},
+		{name: "TiebreakLoserWinsSet", score: "7-6(5-7) 6-4", format: bestOf3},
+		{name: "Incomplete", score: "6-4", format: bestOf3},
+		{name: "TooManySets", score: "6-4 6-4 6-4", format: bestOf3},
+		{name: "SuperTiebreakNotFinal", score: "[10-8] 6-4", format: super},
+		{name: "SuperTiebreakRequired", score: "6-4 4-6 6-4", format: super},
+		{name: "SuperTiebreakNotWonBy2", score: "6-4 4-6 [10-9]", format: super},
+		{name: "InvalidFormat", score: "6-4 6-4", format: Format{BestOf: 4}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			score, err := ParseScore(tt.score, tt.format)
+			if tt.expected == "" {
+				if !errors.Is(err, ErrInvalidScore) {
+					t.Errorf("expected ErrInvalidScore; got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if score.String() != tt.expected {
+				t.Errorf("expected %q; got %q", tt.expected, score.String())
+			}
+			if score.Winner() != tt.winner {
+				t.Errorf("expected %q; got %q", tt.winner, score.Winner())
+			}
+		})
+	}
+}